@@ -4,9 +4,12 @@ import (
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
+	"strings"
 
 	"picproc/mangle"
 	"picproc/orient"
+	"picproc/palcmd"
+	"picproc/palette"
 	"picproc/parallel"
 
 	_ "golang.org/x/image/bmp"
@@ -17,15 +20,18 @@ import (
 )
 
 var cli struct {
-	Workers int           `help:"Number of concurrent workers (if less than 1 use number of CPUs)" default:"1"`
+	Workers int `help:"Number of concurrent workers (if less than 1 use number of CPUs)" default:"1"`
+	palcmd.SearchFlags
 	Orient  orient.CLICmd `cmd:"" help:"Sort files by orientation"`
 	Mangle  mangle.CLICmd `cmd:"" help:"Mangle an image"`
+	Palette palcmd.CLICmd `cmd:"" help:"Manage palettes"`
 }
 
 func main() {
 	kctx := kong.Parse(&cli,
 		kong.Description("picproc processes pictures"),
 		kong.UsageOnError(),
+		kong.Vars{"palettes": strings.Join(palette.RegisteredNames(), ", ")},
 		kong.ConfigureHelp(kong.HelpOptions{
 			Compact: true,
 			Summary: true,
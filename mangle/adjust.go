@@ -0,0 +1,58 @@
+package mangle
+
+import (
+	"image"
+	"image/color"
+	"math"
+
+	"picproc/okcolor"
+)
+
+// adjustOptions shifts hues and scales saturation in Okhsl or Okhsv, where
+// a given saturation looks about as strong for every hue, unlike in HSL and
+// HSV.
+type adjustOptions struct {
+	space      string
+	hueShift   float64 // in radians
+	saturation float64
+}
+
+func (opts adjustOptions) enabled() bool {
+	return (opts.hueShift != 0) || (opts.saturation != 1)
+}
+
+func adjust(img image.Image, opts adjustOptions) image.Image {
+	bounds := img.Bounds()
+	dest := image.NewRGBA64(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			dest.Set(x, y, opts.color(img.At(x, y)))
+		}
+	}
+	return dest
+}
+
+// color adjusts a single color. Saturation is capped to 1, the edge of the
+// sRGB gamut, so that saturated colors are not clipped back unevenly.
+func (opts adjustOptions) color(c color.Color) color.Color {
+	if _, _, _, a := c.RGBA(); a == 0 {
+		return c
+	}
+
+	switch opts.space {
+	case "okhsv":
+		hc := okcolor.OkhsvModel.Convert(c).(okcolor.Okhsv)
+		hc.H += opts.hueShift
+		hc.S = min(hc.S*opts.saturation, 1)
+		return hc
+	default:
+		hc := okcolor.OkhslModel.Convert(c).(okcolor.Okhsl)
+		hc.H += opts.hueShift
+		hc.S = min(hc.S*opts.saturation, 1)
+		return hc
+	}
+}
+
+func degreesToRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
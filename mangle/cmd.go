@@ -23,17 +23,36 @@ import (
 )
 
 type CLICmd struct {
-	Scan      string      `help:"Source folder to scan" default:"."`
-	Dest      string      `help:"Destination folder for processed pictures. Relative to scan dir if not absolute. If same as scan dir, will overwrite source files." default:"mangled"`
-	Resize    bool        `help:"Resize image" default:"false" group:"resize"`
-	Width     int         `help:"Max width" group:"resize"`
-	Height    int         `help:"Max height" group:"resize"`
-	Crop      bool        `help:"Crop image to maintain requested aspect ration" default:"false" group:"resize"`
-	Fill      string      `help:"If given and not cropping, will fill background with this color to maintain destination aspect ratio" group:"resize"`
-	Palette   string      `help:"Palette name (bw, spectra6, mattdm6, gray16, vga16, vga256) or PAL file in RIFF format to apply" group:"palette"`
-	Dither    bool        `help:"Apply dithering" default:"false" group:"palette"`
-	Format    string      `help:"Output format of mangled image. If prefixed with 'unsup:' will convert only unsupported formats" enum:"same,gif,unsup:gif,jpeg,unsup:jpeg,png,unsup:png,bmp,unsup:bmp,tiff,unsup:tiff" default:"unsup:png"`
-	FillColor color.Color `kong:"-"`
+	Scan             string          `help:"Source folder to scan" default:"."`
+	Dest             string          `help:"Destination folder for processed pictures. Relative to scan dir if not absolute. If same as scan dir, will overwrite source files." default:"mangled"`
+	Resize           bool            `help:"Resize image" default:"false" group:"resize"`
+	Width            int             `help:"Max width" group:"resize"`
+	Height           int             `help:"Max height" group:"resize"`
+	Crop             bool            `help:"Crop image to maintain requested aspect ration" default:"false" group:"resize"`
+	Fill             string          `help:"If given and not cropping, will fill background with this color to maintain destination aspect ratio" group:"resize"`
+	HueShift         float64         `help:"Rotate hues by this many degrees" default:"0" group:"adjust"`
+	Saturation       float64         `help:"Multiply saturation by this factor" default:"1" group:"adjust"`
+	AdjustSpace      string          `help:"Color space for hue and saturation adjustments" enum:"okhsl,okhsv" default:"okhsl" group:"adjust"`
+	Palette          string          `help:"Palette name (${palettes}, or one in the palette directories), palette file to apply, or auto:N to generate an N color palette for each image" group:"palette"`
+	PaletteMatch     string          `help:"Palette to match colors against, such as the measured colors of a device. Requires palette-output" group:"palette"`
+	PaletteOutput    string          `help:"Palette written to the output, with the same number of colors as palette-match, such as the nominal colors of a device" group:"palette"`
+	SharedPalette    bool            `help:"Generate a single auto palette from all images instead of one per image" default:"false" group:"palette"`
+	SavePalette      string          `help:"Save the generated shared palette to this PAL file" type:"path" group:"palette"`
+	Quantizer        string          `help:"Method used to generate auto palettes" enum:"median-cut,octree,kmeans" default:"kmeans" group:"palette"`
+	TransparentIndex int             `help:"Palette entry to use for transparent pixels, overriding the one given by the palette" default:"-1" group:"palette"`
+	AlphaThreshold   uint8           `help:"Pixels with alpha below this map to the transparent palette entry" default:"128" group:"palette"`
+	Dither           bool            `help:"Apply dithering" default:"false" group:"palette"`
+	DitherMethod     string          `help:"Dithering method" enum:"floyd-steinberg,blue-noise,white-noise" default:"floyd-steinberg" group:"palette"`
+	Seed             uint64          `help:"Random seed for white noise dithering" default:"0" group:"palette"`
+	Serpentine       bool            `help:"Alternate scan direction on every row when dithering" default:"false" group:"palette"`
+	DitherStrength   float64         `help:"Fraction of the quantization error to diffuse when dithering (0..1)" default:"1" group:"palette"`
+	ColorSpace       string          `help:"Color space used for palette matching and dithering" enum:"srgb,linear,oklab" default:"srgb" group:"palette"`
+	Metric           string          `help:"Color difference metric for palette matching (cie76, cie94, ciede2000, oklab, oklab-weighted[:W], redmean). If not given, colors are matched by distance in the color space" group:"palette"`
+	LUTBits          int             `name:"lut-bits" help:"Cache palette matches in a lookup table with this many bits per channel (1..8, 0 disables)" default:"0" group:"palette"`
+	Format           string          `help:"Output format of mangled image. If prefixed with 'unsup:' will convert only unsupported formats" enum:"same,gif,unsup:gif,jpeg,unsup:jpeg,png,unsup:png,bmp,unsup:bmp,tiff,unsup:tiff" default:"unsup:png"`
+	FillColor        color.Color     `kong:"-"`
+	OutputPalette    color.Palette   `kong:"-"`
+	MatchMetric      *palette.Metric `kong:"-"`
 }
 
 func (c *CLICmd) Validate(kctx *kong.Context) error {
@@ -65,17 +84,94 @@ func (c *CLICmd) Validate(kctx *kong.Context) error {
 	}
 
 	if (!c.Crop) && (c.Fill != "") {
-		if c.FillColor, err = parseHexToColor(c.Fill); err != nil {
+		if c.FillColor, err = palette.ParseHex(c.Fill); err != nil {
+			return err
+		}
+	}
+
+	if c.Saturation < 0 {
+		return fmt.Errorf("invalid saturation factor: %g", c.Saturation)
+	}
+
+	if (c.DitherStrength < 0) || (c.DitherStrength > 1) {
+		return fmt.Errorf("invalid dither strength: %g", c.DitherStrength)
+	}
+
+	if c.Metric != "" {
+		metric, err := palette.ParseMetric(c.Metric)
+		if err != nil {
+			return err
+		}
+		c.MatchMetric = &metric
+	}
+
+	if (c.LUTBits < 0) || (c.LUTBits > 8) {
+		return fmt.Errorf("invalid lookup table size: %d bits", c.LUTBits)
+	}
+
+	if c.TransparentIndex < -1 {
+		return fmt.Errorf("invalid transparent index: %d", c.TransparentIndex)
+	}
+
+	if (c.PaletteMatch != "") || (c.PaletteOutput != "") {
+		if err := c.loadDualPalettes(); err != nil {
 			return err
 		}
 	}
 
 	if c.Palette != "" {
-		if _, err := palette.LoadPalette(c.Palette); err != nil {
+		if n, err := parseAutoPalette(c.Palette); err != nil {
 			return err
+		} else if n == 0 {
+			pal, err := palette.LoadPalette(c.Palette)
+			if err != nil {
+				return err
+			} else if c.TransparentIndex >= len(pal) {
+				return fmt.Errorf("transparent index out of range: %d/%d", c.TransparentIndex, len(pal))
+			}
+		}
+	}
+
+	if c.SharedPalette {
+		if n, _ := parseAutoPalette(c.Palette); n == 0 {
+			return fmt.Errorf("shared palette requires an auto:N palette")
 		}
+	} else if c.SavePalette != "" {
+		return fmt.Errorf("saving a palette requires a shared palette")
+	}
+
+	return nil
+}
+
+// loadDualPalettes checks the palettes given for matching and output, and
+// sets the matching one as the palette to apply.
+func (c *CLICmd) loadDualPalettes() error {
+	switch {
+	case c.PaletteMatch == "":
+		return fmt.Errorf("an output palette requires a palette to match against")
+	case c.PaletteOutput == "":
+		return fmt.Errorf("a palette to match against requires an output palette")
+	case c.Palette != "":
+		return fmt.Errorf("palette cannot be combined with separate match and output palettes")
+	}
+
+	if n, err := parseAutoPalette(c.PaletteMatch); err != nil {
+		return err
+	} else if n != 0 {
+		return fmt.Errorf("palette to match against cannot be generated: %q", c.PaletteMatch)
+	}
+
+	match, err := palette.LoadPalette(c.PaletteMatch)
+	if err != nil {
+		return err
+	}
+	if c.OutputPalette, err = palette.LoadPalette(c.PaletteOutput); err != nil {
+		return err
+	} else if len(match) != len(c.OutputPalette) {
+		return fmt.Errorf("match and output palettes have different sizes: %d/%d", len(match), len(c.OutputPalette))
 	}
 
+	c.Palette = c.PaletteMatch
 	return nil
 }
 
@@ -89,6 +185,39 @@ func (c *CLICmd) Run(worker parallel.WorkerFunc, wait parallel.WaitFunc) error {
 		return fmt.Errorf("unable to read folder %q: %w", c.Scan, err)
 	}
 
+	palOpts := c.paletteOptions()
+	if c.SharedPalette {
+		var fileNames []string
+		for _, file := range files {
+			if !file.IsDir() {
+				fileNames = append(fileNames, file.Name())
+			}
+		}
+
+		n, _ := parseAutoPalette(c.Palette)
+		if palOpts.palette, err = c.sharedPalette(fileNames, n, worker); err != nil {
+			return fmt.Errorf("could not generate shared palette: %w", err)
+		}
+
+		if c.SavePalette != "" {
+			if err = palette.SavePaletteToFile(c.SavePalette, palOpts.palette); err != nil {
+				return err
+			}
+		}
+	}
+
+	// fixed palettes are set up once, as a lookup table can take longer to
+	// fill than an image to process
+	if n, _ := parseAutoPalette(c.Palette); (c.Palette != "") && ((n == 0) || c.SharedPalette) {
+		pal, err := loadPalette(slog.Default(), nil, palOpts)
+		if err != nil {
+			return err
+		}
+		if palOpts.matcher, err = newPaletteMatcher(pal, palOpts); err != nil {
+			return err
+		}
+	}
+
 	var processedCount, errCount atomic.Uint64
 	for _, file := range files {
 		if file.IsDir() {
@@ -123,9 +252,13 @@ func (c *CLICmd) Run(worker parallel.WorkerFunc, wait parallel.WaitFunc) error {
 					}
 				}
 
+				if adjustOpts := c.adjustOptions(); adjustOpts.enabled() {
+					img = adjust(img, adjustOpts)
+				}
+
 				if c.Palette != "" {
 					palLog := logger.With("palette", c.Palette)
-					img, err = repallete(palLog, img, c.Palette, c.Dither)
+					img, err = repallete(palLog, img, palOpts)
 					if err != nil {
 						errCount.Add(1)
 						palLog.Error("could not change image pallete", "error", err)
@@ -156,54 +289,32 @@ func (c *CLICmd) Run(worker parallel.WorkerFunc, wait parallel.WaitFunc) error {
 	return nil
 }
 
-func parseHexToColor(s string) (color.Color, error) {
-	var c color.RGBA
-	switch len(s) {
-	case 4:
-		n, err := fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
-		if err != nil {
-			return nil, fmt.Errorf("could not read color: %w", err)
-		} else if n < 3 {
-			return nil, fmt.Errorf("insufficient fill color fields: %d", n)
-		}
-
-		c.R |= c.R << 4
-		c.G |= c.G << 4
-		c.B |= c.B << 4
-		c.A = 0xFF
-	case 5:
-		n, err := fmt.Sscanf(s, "#%1x%1x%1x%x", &c.R, &c.G, &c.B, &c.A)
-		if err != nil {
-			return nil, fmt.Errorf("could not read color: %w", err)
-		} else if n < 3 {
-			return nil, fmt.Errorf("insufficient fill color fields: %d", n)
-		}
-
-		c.R |= c.R << 4
-		c.G |= c.G << 4
-		c.B |= c.B << 4
-		c.A |= c.A << 4
-	case 7:
-		n, err := fmt.Sscanf(s, "#%1x%1x%1x", &c.R, &c.G, &c.B)
-		if err != nil {
-			return nil, fmt.Errorf("could not read color: %w", err)
-		} else if n < 3 {
-			return nil, fmt.Errorf("insufficient fill color fields: %d", n)
-		}
-
-		c.A = 0xFF
-	case 8:
-		n, err := fmt.Sscanf(s, "#%1x%1x%1x%x", &c.R, &c.G, &c.B, &c.A)
-		if err != nil {
-			return nil, fmt.Errorf("could not read color: %w", err)
-		} else if n < 3 {
-			return nil, fmt.Errorf("insufficient fill color fields: %d", n)
-		}
-	default:
-		return nil, fmt.Errorf("invalid fill color, should be #RGB, #RGBA, #RRGGBB or #RRGGBBAA")
+func (c *CLICmd) adjustOptions() adjustOptions {
+	return adjustOptions{
+		space:      c.AdjustSpace,
+		hueShift:   degreesToRadians(c.HueShift),
+		saturation: c.Saturation,
 	}
+}
 
-	return c, nil
+func (c *CLICmd) paletteOptions() paletteOptions {
+	return paletteOptions{
+		name:             c.Palette,
+		quantizer:        c.Quantizer,
+		space:            c.ColorSpace,
+		lutBits:          c.LUTBits,
+		metric:           c.MatchMetric,
+		output:           c.OutputPalette,
+		dither:           c.Dither,
+		transparentIndex: c.TransparentIndex,
+		ditherOptions: ditherOptions{
+			method:         c.DitherMethod,
+			serpentine:     c.Serpentine,
+			strength:       c.DitherStrength,
+			seed:           c.Seed,
+			alphaThreshold: uint16(c.AlphaThreshold) * 0x101,
+		},
+	}
 }
 
 func save(img image.Image, imgType, outType, destDir, srcName string) (err error) {
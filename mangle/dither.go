@@ -0,0 +1,341 @@
+package mangle
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"picproc/okcolor"
+	"picproc/palette"
+)
+
+type ditherOptions struct {
+	method     string
+	serpentine bool
+	strength   float64
+	seed       uint64
+	// transparent is the palette index given to pixels with alpha below
+	// alphaThreshold, -1 if the palette has no transparent entry
+	transparent    int
+	alphaThreshold uint16
+}
+
+// pixel returns the color of the pixel at (x, y), or false if it maps to the
+// transparent palette entry.
+func (opts ditherOptions) pixel(src image.Image, x, y int) (color.RGBA64, bool) {
+	c := rgba64At(src, x, y)
+	return c, (opts.transparent < 0) || (c.A >= opts.alphaThreshold)
+}
+
+// colorSpace matches colors against a palette and measures quantization
+// error in a given set of coordinates.
+type colorSpace interface {
+	vector(c color.RGBA64) [3]float64
+	index(v [3]float64, a uint16) int
+	clamp(v [3]float64) [3]float64
+	// offset shifts v by d along the lightness axis of the space.
+	offset(v [3]float64, d float64) [3]float64
+	// entry returns the coordinates of the i-th palette color.
+	entry(i int) [3]float64
+	// color converts coordinates back to a color with the given alpha.
+	color(v [3]float64, a uint16) color.Color
+}
+
+// newColorSpace sets up matching against pal in the named space. If a metric
+// is given, it picks the palette color instead of the distance in the space.
+// If lutBits is not zero, matches for opaque colors are cached in a lookup
+// table with lutBits per sRGB channel.
+func newColorSpace(name string, pal color.Palette, metric *palette.Metric, lutBits int) (colorSpace, error) {
+	var cs colorSpace
+	switch name {
+	case "", "srgb":
+		cs = newSRGBSpace(pal)
+	case "linear":
+		cs = newLinearSpace(pal)
+	case "oklab":
+		cs = newOklabSpace(pal)
+	default:
+		return nil, fmt.Errorf("unsupported color space: %q", name)
+	}
+
+	if metric != nil {
+		cs = &metricSpace{colorSpace: cs, idx: palette.NewMetricIndex(pal, *metric)}
+	}
+
+	if lutBits == 0 {
+		return cs, nil
+	}
+
+	lut, err := palette.NewLUT(lutBits, func(c color.Color) int {
+		return cs.index(cs.vector(color.RGBA64Model.Convert(c).(color.RGBA64)), 0xFFFF)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &lutSpace{colorSpace: cs, lut: lut}, nil
+}
+
+// lutSpace looks up opaque colors in a table instead of searching the palette.
+type lutSpace struct {
+	colorSpace
+	lut *palette.LUT
+}
+
+func (s *lutSpace) index(v [3]float64, a uint16) int {
+	if a != 0xFFFF {
+		return s.colorSpace.index(v, a)
+	}
+	return s.lut.Index(s.colorSpace.color(v, a))
+}
+
+// metricSpace matches colors by a color difference metric.
+type metricSpace struct {
+	colorSpace
+	idx *palette.MetricIndex
+}
+
+// index measures the sRGB color the coordinates are shown as. Error diffusion
+// leaves values out of gamut, so they are clipped and clamped before any
+// metric conversion, whichever path it takes.
+func (s *metricSpace) index(v [3]float64, a uint16) int {
+	return s.idx.Index(color.RGBA64Model.Convert(s.colorSpace.color(v, a)))
+}
+
+type srgbSpace struct {
+	pal     *palette.RGBAIndex
+	entries [][3]float64
+}
+
+func newSRGBSpace(pal color.Palette) *srgbSpace {
+	s := &srgbSpace{
+		pal:     palette.NewRGBAIndex(pal),
+		entries: make([][3]float64, len(pal)),
+	}
+	for i, col := range pal {
+		s.entries[i] = s.vector(color.RGBA64Model.Convert(col).(color.RGBA64))
+	}
+	return s
+}
+
+func (s *srgbSpace) vector(c color.RGBA64) [3]float64 {
+	return [3]float64{float64(c.R) / 0xFFFF, float64(c.G) / 0xFFFF, float64(c.B) / 0xFFFF}
+}
+
+func (s *srgbSpace) index(v [3]float64, a uint16) int {
+	return s.pal.Index(s.color(v, a))
+}
+
+func (s *srgbSpace) clamp(v [3]float64) [3]float64 {
+	return [3]float64{clamp01(v[0]), clamp01(v[1]), clamp01(v[2])}
+}
+
+func (s *srgbSpace) offset(v [3]float64, d float64) [3]float64 {
+	return [3]float64{v[0] + d, v[1] + d, v[2] + d}
+}
+
+func (s *srgbSpace) entry(i int) [3]float64 {
+	return s.entries[i]
+}
+
+func (s *srgbSpace) color(v [3]float64, a uint16) color.Color {
+	return color.RGBA64{
+		R: uint16(v[0]*0xFFFF + 0.5),
+		G: uint16(v[1]*0xFFFF + 0.5),
+		B: uint16(v[2]*0xFFFF + 0.5),
+		A: a,
+	}
+}
+
+type linearSpace struct {
+	pal *palette.LinearRGBA
+	idx *palette.LinearRGBAIndex
+}
+
+func newLinearSpace(pal color.Palette) *linearSpace {
+	lin := palette.NewLinearRGBAPalette(pal)
+	return &linearSpace{pal: lin, idx: palette.NewLinearRGBAIndex(*lin)}
+}
+
+func (s *linearSpace) vector(c color.RGBA64) [3]float64 {
+	lc := okcolor.LinearRGBAModel.Convert(c).(okcolor.LinearRGBA)
+	return [3]float64{lc.R, lc.G, lc.B}
+}
+
+func (s *linearSpace) index(v [3]float64, a uint16) int {
+	return s.idx.Index(okcolor.LinearRGBA{R: v[0], G: v[1], B: v[2], A: a})
+}
+
+func (s *linearSpace) clamp(v [3]float64) [3]float64 {
+	return [3]float64{clamp01(v[0]), clamp01(v[1]), clamp01(v[2])}
+}
+
+func (s *linearSpace) offset(v [3]float64, d float64) [3]float64 {
+	return [3]float64{v[0] + d, v[1] + d, v[2] + d}
+}
+
+func (s *linearSpace) entry(i int) [3]float64 {
+	lc := (*s.pal)[i]
+	return [3]float64{lc.R, lc.G, lc.B}
+}
+
+func (s *linearSpace) color(v [3]float64, a uint16) color.Color {
+	return okcolor.LinearRGBA{R: v[0], G: v[1], B: v[2], A: a}
+}
+
+type oklabSpace struct {
+	pal *palette.Lab
+	idx *palette.LabIndex
+}
+
+func newOklabSpace(pal color.Palette) *oklabSpace {
+	lab := palette.NewLabPalette(pal)
+	return &oklabSpace{pal: lab, idx: palette.NewLabIndex(*lab)}
+}
+
+func (s *oklabSpace) vector(c color.RGBA64) [3]float64 {
+	lc := okcolor.LabModel.Convert(c).(okcolor.Lab)
+	return [3]float64{lc.L, lc.A, lc.B}
+}
+
+func (s *oklabSpace) index(v [3]float64, a uint16) int {
+	return s.idx.Index(okcolor.Lab{L: v[0], A: v[1], B: v[2], Alpha: a})
+}
+
+// clamp keeps lightness in range and chroma within a margin of the sRGB
+// gamut, so accumulated error cannot run away.
+func (s *oklabSpace) clamp(v [3]float64) [3]float64 {
+	return [3]float64{clamp01(v[0]), min(max(v[1], -0.4), 0.4), min(max(v[2], -0.4), 0.4)}
+}
+
+func (s *oklabSpace) offset(v [3]float64, d float64) [3]float64 {
+	return [3]float64{v[0] + d, v[1], v[2]}
+}
+
+func (s *oklabSpace) entry(i int) [3]float64 {
+	lc := (*s.pal)[i]
+	return [3]float64{lc.L, lc.A, lc.B}
+}
+
+func (s *oklabSpace) color(v [3]float64, a uint16) color.Color {
+	return okcolor.Lab{L: v[0], A: v[1], B: v[2], Alpha: a}
+}
+
+// floydSteinberg draws src onto dst, matching colors in the given space and
+// diffusing the quantization error of each pixel to its unprocessed neighbours.
+// The error is scaled by opts.strength and, if opts.serpentine is set, every
+// other row is scanned right to left. A zero strength gives plain nearest
+// color mapping. Indexes in cs are translated to dst palette indexes through
+// indices.
+func floydSteinberg(dst *image.Paletted, r image.Rectangle, src image.Image, sp image.Point, cs colorSpace, indices []uint8, opts ditherOptions) {
+	r = r.Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	// error buffers for the current and next row, padded by one pixel on each side
+	width := r.Dx()
+	curErr := make([][3]float64, width+2)
+	nextErr := make([][3]float64, width+2)
+
+	for y := range r.Dy() {
+		x0, dx := 0, 1
+		if opts.serpentine && (y%2 == 1) {
+			x0, dx = width-1, -1
+		}
+
+		for i, x := 0, x0; i < width; i, x = i+1, x+dx {
+			c, ok := opts.pixel(src, sp.X+x, sp.Y+y)
+			if !ok {
+				dst.SetColorIndex(r.Min.X+x, r.Min.Y+y, uint8(opts.transparent))
+				continue
+			}
+
+			v := cs.vector(c)
+			if opts.strength == 0 {
+				dst.SetColorIndex(r.Min.X+x, r.Min.Y+y, indices[cs.index(v, c.A)])
+				continue
+			}
+
+			for ch := range v {
+				v[ch] += curErr[x+1][ch]
+			}
+			v = cs.clamp(v)
+
+			idx := cs.index(v, c.A)
+			dst.SetColorIndex(r.Min.X+x, r.Min.Y+y, indices[idx])
+
+			pv := cs.entry(idx)
+			for ch := range v {
+				e := (v[ch] - pv[ch]) * opts.strength
+				curErr[x+1+dx][ch] += e * 7 / 16
+				nextErr[x+1-dx][ch] += e * 3 / 16
+				nextErr[x+1][ch] += e * 5 / 16
+				nextErr[x+1+dx][ch] += e * 1 / 16
+			}
+		}
+
+		curErr, nextErr = nextErr, curErr
+		clear(nextErr)
+	}
+}
+
+// thresholdDither draws src onto dst, offsetting each pixel by a threshold
+// map value before matching it to the nearest palette color. The amplitude of
+// the offset is the average distance between neighbouring palette colors,
+// scaled by strength.
+func thresholdDither(dst *image.Paletted, r image.Rectangle, src image.Image, sp image.Point, cs colorSpace, indices []uint8, opts ditherOptions, threshold func(x, y int) float64) {
+	r = r.Intersect(dst.Bounds())
+	if r.Empty() {
+		return
+	}
+
+	spread := paletteSpread(cs, len(indices)) * opts.strength
+	for y := range r.Dy() {
+		for x := range r.Dx() {
+			c, ok := opts.pixel(src, sp.X+x, sp.Y+y)
+			if !ok {
+				dst.SetColorIndex(r.Min.X+x, r.Min.Y+y, uint8(opts.transparent))
+				continue
+			}
+
+			v := cs.clamp(cs.offset(cs.vector(c), (threshold(x, y)-0.5)*spread))
+			dst.SetColorIndex(r.Min.X+x, r.Min.Y+y, indices[cs.index(v, c.A)])
+		}
+	}
+}
+
+// paletteSpread returns the mean distance from each palette color to its
+// nearest neighbour.
+func paletteSpread(cs colorSpace, n int) float64 {
+	if n < 2 {
+		return 0
+	}
+
+	var sum float64
+	for i := range n {
+		vi := cs.entry(i)
+		best := math.MaxFloat64
+		for j := range n {
+			if i == j {
+				continue
+			}
+			vj := cs.entry(j)
+			d0, d1, d2 := vi[0]-vj[0], vi[1]-vj[1], vi[2]-vj[2]
+			best = min(best, d0*d0+d1*d1+d2*d2)
+		}
+		sum += math.Sqrt(best)
+	}
+	return sum / float64(n)
+}
+
+func rgba64At(img image.Image, x, y int) color.RGBA64 {
+	if rgba64, ok := img.(image.RGBA64Image); ok {
+		return rgba64.RGBA64At(x, y)
+	}
+	return color.RGBA64Model.Convert(img.At(x, y)).(color.RGBA64)
+}
+
+func clamp01(x float64) float64 {
+	return min(max(x, 0), 1)
+}
@@ -0,0 +1,118 @@
+package mangle
+
+import (
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+const (
+	blueNoiseSize  = 64
+	blueNoiseSigma = 1.5
+)
+
+// blueNoise is a tileable threshold map in [0, 1) generated once with the
+// void-and-cluster method (Ulichney, 1993).
+var blueNoise = sync.OnceValue(func() []float64 {
+	return voidAndCluster(blueNoiseSize, blueNoiseSigma, rand.New(rand.NewPCG(0x5EED, 0xB1E)))
+})
+
+func blueNoiseAt(x, y int) float64 {
+	x = ((x % blueNoiseSize) + blueNoiseSize) % blueNoiseSize
+	y = ((y % blueNoiseSize) + blueNoiseSize) % blueNoiseSize
+	return blueNoise()[y*blueNoiseSize+x]
+}
+
+// voidAndCluster ranks every cell of a size x size toroidal grid so that each
+// prefix of the ranking is evenly spread out, and returns the ranks normalized
+// to [0, 1).
+func voidAndCluster(size int, sigma float64, rnd *rand.Rand) []float64 {
+	n := size * size
+
+	// gaussian energy kernel, wrapped around the edges
+	kernel := make([]float64, n)
+	for y := range size {
+		dy := float64(min(y, size-y))
+		for x := range size {
+			dx := float64(min(x, size-x))
+			kernel[y*size+x] = math.Exp(-(dx*dx + dy*dy) / (2 * sigma * sigma))
+		}
+	}
+
+	pattern := make([]bool, n)
+	energy := make([]float64, n)
+	toggle := func(i int, on bool) {
+		pattern[i] = on
+		sign := 1.0
+		if !on {
+			sign = -1
+		}
+		px, py := i%size, i/size
+		for y := range size {
+			ky := ((y - py + size) % size) * size
+			for x := range size {
+				energy[y*size+x] += sign * kernel[ky+(x-px+size)%size]
+			}
+		}
+	}
+
+	// tightest cluster is the set cell with the highest energy,
+	// largest void is the unset cell with the lowest one
+	extreme := func(set bool) int {
+		best, bestEnergy := -1, 0.0
+		for i, e := range energy {
+			if pattern[i] != set {
+				continue
+			}
+			if (best < 0) || (set && (e > bestEnergy)) || (!set && (e < bestEnergy)) {
+				best, bestEnergy = i, e
+			}
+		}
+		return best
+	}
+
+	// initial binary pattern: random points, relaxed until the tightest
+	// cluster and the largest void coincide
+	ones := n / 10
+	for _, i := range rnd.Perm(n)[:ones] {
+		toggle(i, true)
+	}
+	for {
+		cluster := extreme(true)
+		toggle(cluster, false)
+		void := extreme(false)
+		if void == cluster {
+			toggle(cluster, true)
+			break
+		}
+		toggle(void, true)
+	}
+	initial := make([]bool, n)
+	copy(initial, pattern)
+	initialEnergy := make([]float64, n)
+	copy(initialEnergy, energy)
+
+	rank := make([]int, n)
+
+	// phase 1: remove tightest clusters from the initial pattern
+	for r := ones - 1; r >= 0; r-- {
+		cluster := extreme(true)
+		toggle(cluster, false)
+		rank[cluster] = r
+	}
+
+	// phases 2 and 3: fill largest voids starting again from the initial pattern
+	copy(pattern, initial)
+	copy(energy, initialEnergy)
+	for r := ones; r < n; r++ {
+		void := extreme(false)
+		toggle(void, true)
+		rank[void] = r
+	}
+
+	res := make([]float64, n)
+	for i, r := range rank {
+		res[i] = (float64(r) + 0.5) / float64(n)
+	}
+	return res
+}
@@ -1,29 +1,195 @@
 package mangle
 
 import (
+	"fmt"
 	"image"
+	"image/color"
 	"log/slog"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 
 	"picproc/palette"
-
-	"golang.org/x/image/draw"
 )
 
-func repallete(logger *slog.Logger, img image.Image, palName string, dither bool) (image.Image, error) {
-	pal, err := palette.LoadPalette(palName)
+const autoPalettePrefix = "auto:"
+
+type paletteOptions struct {
+	name      string
+	quantizer string
+	space     string
+	lutBits   int
+	metric    *palette.Metric
+	dither    bool
+	// transparentIndex overrides the transparent palette entry if not -1
+	transparentIndex int
+	// palette, if set, is used instead of loading or generating one
+	palette color.Palette
+	// matcher, if set, is used instead of setting up matching against the
+	// palette for each image
+	matcher *paletteMatcher
+	// output, if set, replaces the colors of the palette in the result,
+	// entry for entry
+	output color.Palette
+	ditherOptions
+}
+
+// parseAutoPalette returns the number of colors requested by an "auto:N"
+// palette name, or 0 if name refers to a regular palette.
+func parseAutoPalette(name string) (int, error) {
+	count, ok := strings.CutPrefix(strings.ToLower(name), autoPalettePrefix)
+	if !ok {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number of colors in palette %q: %w", name, err)
+	} else if (n < 1) || (n > 256) {
+		return 0, fmt.Errorf("number of colors in palette %q must be between 1 and 256", name)
+	}
+
+	return n, nil
+}
+
+func loadPalette(logger *slog.Logger, img image.Image, opts paletteOptions) (color.Palette, error) {
+	if opts.palette != nil {
+		return opts.palette, nil
+	}
+
+	n, err := parseAutoPalette(opts.name)
 	if err != nil {
 		return nil, err
+	} else if n == 0 {
+		return palette.LoadPalette(opts.name)
+	}
+
+	// keep a slot for transparent pixels
+	transparent := (n > 1) && hasTransparent(img, opts.alphaThreshold)
+	if transparent {
+		n--
 	}
 
-	logger.Info("applying palette", "colors", len(pal))
+	logger.Info("generating palette", "colors", n, "quantizer", opts.quantizer, "transparent", transparent)
+	lab, err := palette.Quantize(img, n, opts.quantizer)
+	if err != nil {
+		return nil, err
+	}
+
+	_, pal := lab.To(color.RGBAModel)
+	if transparent {
+		pal = append(pal, color.NRGBA{})
+	}
+	return pal, nil
+}
+
+// hasTransparent reports whether any pixel of img has alpha below threshold.
+func hasTransparent(img image.Image, threshold uint16) bool {
+	if opaque, ok := img.(interface{ Opaque() bool }); ok && opaque.Opaque() {
+		return false
+	}
+
+	r := img.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < uint32(threshold) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// opaqueEntries returns the palette without its transparent entry, along
+// with the palette index of each remaining color.
+func opaqueEntries(pal color.Palette, transparent int) (color.Palette, []uint8) {
+	res := make(color.Palette, 0, len(pal))
+	indices := make([]uint8, 0, len(pal))
+	for i, c := range pal {
+		if i != transparent {
+			res = append(res, c)
+			indices = append(indices, uint8(i))
+		}
+	}
+	return res, indices
+}
+
+// paletteMatcher is a palette ready to be applied: its opaque colors set up
+// for matching in a color space. It is not modified once built, so a fixed
+// palette is set up once and shared by all images.
+type paletteMatcher struct {
+	pal         color.Palette
+	transparent int
+	// indices translates color space indexes to palette indexes
+	indices []uint8
+	cs      colorSpace
+}
+
+func newPaletteMatcher(pal color.Palette, opts paletteOptions) (*paletteMatcher, error) {
+	var err error
+	if opts.transparentIndex >= 0 {
+		if pal, err = palette.SetTransparent(pal, opts.transparentIndex); err != nil {
+			return nil, err
+		}
+	}
+
+	m := &paletteMatcher{pal: pal, transparent: palette.TransparentIndex(pal)}
+	match, indices := opaqueEntries(pal, m.transparent)
+	if len(match) == 0 {
+		return nil, fmt.Errorf("palette has no opaque colors")
+	}
+	m.indices = indices
+
+	if m.cs, err = newColorSpace(opts.space, match, opts.metric, opts.lutBits); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func repallete(logger *slog.Logger, img image.Image, opts paletteOptions) (image.Image, error) {
+	m := opts.matcher
+	if m == nil {
+		pal, err := loadPalette(logger, img, opts)
+		if err != nil {
+			return nil, err
+		}
+		if m, err = newPaletteMatcher(pal, opts); err != nil {
+			return nil, err
+		}
+	}
+	pal, cs, indices := m.pal, m.cs, m.indices
+	opts.transparent = m.transparent
+
+	logger.Info("applying palette", "colors", len(pal), "space", opts.space, "transparent", opts.transparent)
 	sr := img.Bounds()
 	dr := image.Rect(0, 0, sr.Dx(), sr.Dy())
 	dest := image.NewPaletted(dr, pal)
 
-	if dither {
-		draw.FloydSteinberg.Draw(dest, dr, img, sr.Min)
-	} else {
-		draw.Draw(dest, dr, img, dr.Min, draw.Src)
+	switch {
+	case !opts.dither:
+		opts.strength = 0
+		floydSteinberg(dest, dr, img, sr.Min, cs, indices, opts.ditherOptions)
+	case opts.method == "blue-noise":
+		thresholdDither(dest, dr, img, sr.Min, cs, indices, opts.ditherOptions, blueNoiseAt)
+	case opts.method == "white-noise":
+		rnd := rand.New(rand.NewPCG(opts.seed, opts.seed))
+		thresholdDither(dest, dr, img, sr.Min, cs, indices, opts.ditherOptions, func(int, int) float64 {
+			return rnd.Float64()
+		})
+	default:
+		floydSteinberg(dest, dr, img, sr.Min, cs, indices, opts.ditherOptions)
 	}
-	return dest, err
+
+	if opts.output != nil {
+		logger.Info("replacing palette colors", "colors", len(opts.output))
+		dest.Palette = opts.output
+		if opts.transparent >= 0 {
+			var err error
+			if dest.Palette, err = palette.SetTransparent(opts.output, opts.transparent); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return dest, nil
 }
@@ -0,0 +1,94 @@
+package mangle
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"picproc/okcolor"
+	"picproc/palette"
+	"picproc/parallel"
+)
+
+// samplesPerImage caps how many pixels of each image contribute to a shared
+// palette.
+const samplesPerImage = 1 << 14
+
+// sharedPalette samples colors from every image in fileNames and builds a
+// single n color palette out of them. Like a per-image auto palette, it keeps
+// a slot for transparent pixels if any image has some.
+func (c *CLICmd) sharedPalette(fileNames []string, n int, worker parallel.WorkerFunc) (color.Palette, error) {
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		samples     []okcolor.Lab
+		transparent bool
+	)
+	threshold := uint16(c.AlphaThreshold) * 0x101
+	adjustOpts := c.adjustOptions()
+
+	for _, fileName := range fileNames {
+		wg.Add(1)
+		worker(func() {
+			defer wg.Done()
+
+			filePath := filepath.Join(c.Scan, fileName)
+			img, _, err := decodeImage(filePath)
+			if err != nil {
+				slog.Warn("could not sample image", "file", filePath, "error", err)
+				return
+			}
+			if adjustOpts.enabled() {
+				img = adjust(img, adjustOpts)
+			}
+
+			imgSamples := palette.SampleImage(img, samplesPerImage)
+			imgTransparent := hasTransparent(img, threshold)
+			mu.Lock()
+			samples = append(samples, imgSamples...)
+			transparent = transparent || imgTransparent
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no colors sampled from %q", c.Scan)
+	}
+
+	// keep a slot for transparent pixels
+	transparent = transparent && (n > 1)
+	if transparent {
+		n--
+	}
+
+	slog.Info("generating shared palette", "colors", n, "quantizer", c.Quantizer, "samples", len(samples), "transparent", transparent)
+	lab, err := palette.QuantizeSamples(samples, n, c.Quantizer)
+	if err != nil {
+		return nil, err
+	}
+
+	_, pal := lab.To(color.RGBAModel)
+	if transparent {
+		pal = append(pal, color.NRGBA{})
+	}
+	return pal, nil
+}
+
+func decodeImage(filePath string) (image.Image, string, error) {
+	imgFile, err := os.Open(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not open image: %w", err)
+	}
+	defer imgFile.Close()
+
+	img, imgType, err := image.Decode(imgFile)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not decode image: %w", err)
+	}
+	return img, imgType, nil
+}
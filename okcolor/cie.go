@@ -0,0 +1,327 @@
+// based on:
+// http://www.brucelindbloom.com/index.html?Math.html
+
+package okcolor
+
+import (
+	"image/color"
+	"math"
+)
+
+// D50 reference white, used by ICC profiles and print oriented formats
+var d50White = [3]float64{0.96422, 1, 0.82521}
+
+const (
+	cieEpsilon = 216.0 / 24389.0
+	cieKappa   = 24389.0 / 27.0
+)
+
+// xyzColor is implemented by the colors that convert exactly to XYZ, so
+// conversions between them skip the sRGB gamut clipping of RGBA.
+type xyzColor interface {
+	XYZ() XYZ
+}
+
+// XYZ is a CIE 1931 XYZ color relative to the D65 white point of sRGB, with
+// Y = 1 for white.
+type XYZ struct {
+	X     float64
+	Y     float64 // relative luminance
+	Z     float64
+	Alpha uint16 // alpha
+}
+
+var XYZModel = color.ModelFunc(xyzConvert)
+
+func xyzConvert(c color.Color) color.Color {
+	if xc, ok := c.(xyzColor); ok {
+		return xc.XYZ()
+	}
+	return linearRGBAConvert(c).(LinearRGBA).XYZ()
+}
+
+func (xc XYZ) RGBA() (uint32, uint32, uint32, uint32) {
+	return xc.LinearRGBA().RGBA()
+}
+
+func (xc XYZ) XYZ() XYZ {
+	return xc
+}
+
+// LinearRGBA converts the color to linear sRGB. The result may be out of the
+// sRGB gamut.
+func (xc XYZ) LinearRGBA() LinearRGBA {
+	return LinearRGBA{
+		R: 3.2404542*xc.X - 1.5371385*xc.Y - 0.4985314*xc.Z,
+		G: -0.9692660*xc.X + 1.8760108*xc.Y + 0.0415560*xc.Z,
+		B: 0.0556434*xc.X - 0.2040259*xc.Y + 1.0572252*xc.Z,
+		A: xc.Alpha,
+	}
+}
+
+// XYZD50 adapts the color to a D50 white point with the Bradford transform.
+func (xc XYZ) XYZD50() XYZD50 {
+	v := mulVec(bradfordD65ToD50, [3]float64{xc.X, xc.Y, xc.Z})
+	return XYZD50{X: v[0], Y: v[1], Z: v[2], Alpha: xc.Alpha}
+}
+
+func (lc LinearRGBA) XYZ() XYZ {
+	v := mulVec(linearSRGBToXYZ, [3]float64{lc.R, lc.G, lc.B})
+	return XYZ{X: v[0], Y: v[1], Z: v[2], Alpha: lc.A}
+}
+
+// XYZD50 is a CIE 1931 XYZ color relative to a D50 white point, as used by
+// ICC profiles, with Y = 1 for white. Colors are adapted from and to D65 with
+// the Bradford transform.
+type XYZD50 struct {
+	X     float64
+	Y     float64 // relative luminance
+	Z     float64
+	Alpha uint16 // alpha
+}
+
+var XYZD50Model = color.ModelFunc(xyzD50Convert)
+
+func xyzD50Convert(c color.Color) color.Color {
+	switch xc := c.(type) {
+	case XYZD50:
+		return c
+	case CIELab:
+		return xc.XYZD50()
+	case CIELCh:
+		return xc.CIELab().XYZD50()
+	case CIELuv:
+		return xc.XYZD50()
+	}
+	return xyzConvert(c).(XYZ).XYZD50()
+}
+
+func (xc XYZD50) RGBA() (uint32, uint32, uint32, uint32) {
+	return xc.LinearRGBA().RGBA()
+}
+
+// XYZ adapts the color to the D65 white point with the Bradford transform.
+func (xc XYZD50) XYZ() XYZ {
+	v := mulVec(bradfordD50ToD65, [3]float64{xc.X, xc.Y, xc.Z})
+	return XYZ{X: v[0], Y: v[1], Z: v[2], Alpha: xc.Alpha}
+}
+
+// LinearRGBA converts the color to linear sRGB. The result may be out of the
+// sRGB gamut.
+func (xc XYZD50) LinearRGBA() LinearRGBA {
+	return xc.XYZ().LinearRGBA()
+}
+
+func (xc XYZD50) CIELab() CIELab {
+	fx := cieF(xc.X / d50White[0])
+	fy := cieF(xc.Y / d50White[1])
+	fz := cieF(xc.Z / d50White[2])
+
+	return CIELab{
+		L:     116*fy - 16,
+		A:     500 * (fx - fy),
+		B:     200 * (fy - fz),
+		Alpha: xc.Alpha,
+	}
+}
+
+func (xc XYZD50) CIELuv() CIELuv {
+	L := 116*cieF(xc.Y/d50White[1]) - 16
+	u, v := chromaticity(xc.X, xc.Y, xc.Z)
+	un, vn := chromaticity(d50White[0], d50White[1], d50White[2])
+
+	return CIELuv{
+		L:     L,
+		U:     13 * L * (u - un),
+		V:     13 * L * (v - vn),
+		Alpha: xc.Alpha,
+	}
+}
+
+func cieF(t float64) float64 {
+	if t > cieEpsilon {
+		return math.Cbrt(t)
+	}
+	return (cieKappa*t + 16) / 116
+}
+
+func cieFInv(f float64) float64 {
+	if f3 := f * f * f; f3 > cieEpsilon {
+		return f3
+	}
+	return (116*f - 16) / cieKappa
+}
+
+// lightnessToY converts CIE lightness, in [0, 100], to relative luminance.
+func lightnessToY(L float64) float64 {
+	if L > cieKappa*cieEpsilon {
+		y := (L + 16) / 116
+		return y * y * y
+	}
+	return L / cieKappa
+}
+
+// chromaticity returns the CIE 1976 u' and v' chromaticity coordinates.
+func chromaticity(x, y, z float64) (float64, float64) {
+	d := x + 15*y + 3*z
+	if d == 0 {
+		return 0, 0
+	}
+	return 4 * x / d, 9 * y / d
+}
+
+// CIELab is a CIELAB color relative to a D50 white point, as used by ICC
+// profiles and Adobe palettes, with L in [0, 100].
+type CIELab struct {
+	L     float64 // lightness
+	A     float64 // how green/red the color is
+	B     float64 // how blue/yellow the color is
+	Alpha uint16  // alpha
+}
+
+var CIELabModel = color.ModelFunc(cieLabConvert)
+
+func cieLabConvert(c color.Color) color.Color {
+	switch lc := c.(type) {
+	case CIELab:
+		return c
+	case CIELCh:
+		return lc.CIELab()
+	}
+	return xyzD50Convert(c).(XYZD50).CIELab()
+}
+
+func (lc CIELab) RGBA() (uint32, uint32, uint32, uint32) {
+	return lc.LinearRGBA().RGBA()
+}
+
+func (lc CIELab) XYZD50() XYZD50 {
+	fy := (lc.L + 16) / 116
+	fx := fy + lc.A/500
+	fz := fy - lc.B/200
+
+	return XYZD50{
+		X:     cieFInv(fx) * d50White[0],
+		Y:     lightnessToY(lc.L) * d50White[1],
+		Z:     cieFInv(fz) * d50White[2],
+		Alpha: lc.Alpha,
+	}
+}
+
+func (lc CIELab) XYZ() XYZ {
+	return lc.XYZD50().XYZ()
+}
+
+// LinearRGBA converts the color to linear sRGB. The result may be out of the
+// sRGB gamut.
+func (lc CIELab) LinearRGBA() LinearRGBA {
+	return lc.XYZ().LinearRGBA()
+}
+
+func (lc CIELab) CIELCh() CIELCh {
+	return CIELCh{
+		L:     lc.L,
+		C:     math.Sqrt((lc.A * lc.A) + (lc.B * lc.B)),
+		H:     math.Atan2(lc.B, lc.A),
+		Alpha: lc.Alpha,
+	}
+}
+
+// CIELab converts the color to CIELAB relative to a D50 white point.
+func (lc LinearRGBA) CIELab() CIELab {
+	return lc.XYZ().XYZD50().CIELab()
+}
+
+// CIELCh is the cylindrical form of CIELab, LCh(ab).
+type CIELCh struct {
+	L     float64 // lightness
+	C     float64 // chroma
+	H     float64 // hue, in radians
+	Alpha uint16  // alpha
+}
+
+var CIELChModel = color.ModelFunc(cieLChConvert)
+
+func cieLChConvert(c color.Color) color.Color {
+	if _, ok := c.(CIELCh); ok {
+		return c
+	}
+	return cieLabConvert(c).(CIELab).CIELCh()
+}
+
+func (lc CIELCh) RGBA() (uint32, uint32, uint32, uint32) {
+	return lc.LinearRGBA().RGBA()
+}
+
+func (lc CIELCh) CIELab() CIELab {
+	return CIELab{
+		L:     lc.L,
+		A:     lc.C * math.Cos(lc.H),
+		B:     lc.C * math.Sin(lc.H),
+		Alpha: lc.Alpha,
+	}
+}
+
+func (lc CIELCh) XYZ() XYZ {
+	return lc.CIELab().XYZ()
+}
+
+// LinearRGBA converts the color to linear sRGB. The result may be out of the
+// sRGB gamut.
+func (lc CIELCh) LinearRGBA() LinearRGBA {
+	return lc.CIELab().LinearRGBA()
+}
+
+// CIELuv is a CIELUV color relative to a D50 white point, like CIELab, with
+// L in [0, 100].
+type CIELuv struct {
+	L     float64 // lightness
+	U     float64 // how green/red the color is
+	V     float64 // how blue/yellow the color is
+	Alpha uint16  // alpha
+}
+
+var CIELuvModel = color.ModelFunc(cieLuvConvert)
+
+func cieLuvConvert(c color.Color) color.Color {
+	if _, ok := c.(CIELuv); ok {
+		return c
+	}
+	return xyzD50Convert(c).(XYZD50).CIELuv()
+}
+
+func (lc CIELuv) RGBA() (uint32, uint32, uint32, uint32) {
+	return lc.LinearRGBA().RGBA()
+}
+
+func (lc CIELuv) XYZD50() XYZD50 {
+	if lc.L <= 0 {
+		return XYZD50{Alpha: lc.Alpha}
+	}
+
+	un, vn := chromaticity(d50White[0], d50White[1], d50White[2])
+	u := lc.U/(13*lc.L) + un
+	v := lc.V/(13*lc.L) + vn
+	y := lightnessToY(lc.L) * d50White[1]
+	if v <= 0 {
+		// no real color has v' <= 0, only its luminance is kept
+		return XYZD50{Y: y, Alpha: lc.Alpha}
+	}
+
+	return XYZD50{
+		X:     y * 9 * u / (4 * v),
+		Y:     y,
+		Z:     y * (12 - 3*u - 20*v) / (4 * v),
+		Alpha: lc.Alpha,
+	}
+}
+
+func (lc CIELuv) XYZ() XYZ {
+	return lc.XYZD50().XYZ()
+}
+
+// LinearRGBA converts the color to linear sRGB. The result may be out of the
+// sRGB gamut.
+func (lc CIELuv) LinearRGBA() LinearRGBA {
+	return lc.XYZD50().LinearRGBA()
+}
@@ -0,0 +1,58 @@
+package okcolor
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestCIELuvRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		in   color.Color
+	}{
+		{name: "black", in: color.NRGBA{A: 0xFF}},
+		{name: "white", in: color.NRGBA{R: 0xFF, G: 0xFF, B: 0xFF, A: 0xFF}},
+		{name: "red", in: color.NRGBA{R: 0xFF, A: 0xFF}},
+		{name: "blue", in: color.NRGBA{B: 0xFF, A: 0xFF}},
+		{name: "translucent", in: color.NRGBA{R: 0x20, G: 0x80, B: 0xC0, A: 0x80}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := XYZD50Model.Convert(tt.in).(XYZD50)
+			luv := want.CIELuv()
+			got := luv.XYZD50()
+			if (math.Abs(got.X-want.X) > 1e-9) || (math.Abs(got.Y-want.Y) > 1e-9) ||
+				(math.Abs(got.Z-want.Z) > 1e-9) || (got.Alpha != want.Alpha) {
+				t.Fatalf("got %+v through %+v, want %+v", got, luv, want)
+			}
+		})
+	}
+}
+
+func TestCIELuvExtremes(t *testing.T) {
+	_, vn := chromaticity(d50White[0], d50White[1], d50White[2])
+	tests := []CIELuv{
+		{L: 50, U: 0, V: -13 * 50 * vn},
+		{L: 50, U: 1000, V: 1000},
+		{L: 50, U: -1000, V: -1000},
+		{L: 50, U: 0, V: -1000},
+		{L: 100, U: 1e9, V: 0},
+		{L: 0, U: 100, V: -100},
+		{L: -10, U: 0, V: 0},
+	}
+
+	for _, lc := range tests {
+		lc.Alpha = 0xFFFF
+		xc := lc.XYZD50()
+		for _, f := range []float64{xc.X, xc.Y, xc.Z} {
+			if math.IsNaN(f) || math.IsInf(f, 0) {
+				t.Fatalf("%+v converts to %+v", lc, xc)
+			}
+		}
+		if _, _, _, a := lc.RGBA(); a != 0xFFFF {
+			t.Fatalf("%+v has alpha %#x, want 0xffff", lc, a)
+		}
+	}
+}
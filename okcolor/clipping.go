@@ -10,25 +10,65 @@ const eps = 0.00001
 type Clipper func(Lab) Lab
 
 func GamutClipPreserveChroma(lc Lab) Lab {
-	return GamutClipProjectToL0(lc, clamp(lc.L, 0, 1))
+	return SRGB.GamutClipPreserveChroma(lc)
 }
 
 func GamutClipProjectTo05(lc Lab) Lab {
-	return GamutClipProjectToL0(lc, 0.5)
+	return SRGB.GamutClipProjectTo05(lc)
 }
 
 func GamutClipperProjectToL0(L0 float64) Clipper {
+	return SRGB.GamutClipperProjectToL0(L0)
+}
+
+func GamutClipProjectToL0(lc Lab, L0 float64) Lab {
+	return SRGB.GamutClipProjectToL0(lc, L0)
+}
+
+func GamutClipProjectToLCusp(lc Lab) Lab {
+	return SRGB.GamutClipProjectToLCusp(lc)
+}
+
+func GamutClipperAdaptive05(alpha float64) Clipper {
+	return SRGB.GamutClipperAdaptive05(alpha)
+}
+
+func GamutClipAdaptive05(lc Lab, alpha float64) Lab {
+	return SRGB.GamutClipAdaptive05(lc, alpha)
+}
+
+func GamutClipperAdaptiveLCusp(alpha float64) Clipper {
+	return SRGB.GamutClipperAdaptiveLCusp(alpha)
+}
+
+func GamutClipAdaptiveLCusp(lc Lab, alpha float64) Lab {
+	return SRGB.GamutClipAdaptiveLCusp(lc, alpha)
+}
+
+// The clippers below map Oklab colors into the gamut of the space. For other
+// spaces than sRGB, convert the result with s.FromLab.
+
+func (s *RGBSpace) GamutClipPreserveChroma(lc Lab) Lab {
+	return s.GamutClipProjectToL0(lc, clamp(lc.L, 0, 1))
+}
+
+func (s *RGBSpace) GamutClipProjectTo05(lc Lab) Lab {
+	return s.GamutClipProjectToL0(lc, 0.5)
+}
+
+func (s *RGBSpace) GamutClipperProjectToL0(L0 float64) Clipper {
 	return func(lc Lab) Lab {
-		return GamutClipProjectToL0(lc, L0)
+		return s.GamutClipProjectToL0(lc, L0)
 	}
 }
 
-func GamutClipProjectToL0(lc Lab, L0 float64) Lab {
+func (s *RGBSpace) GamutClipProjectToL0(lc Lab, L0 float64) Lab {
 	c := max(eps, math.Sqrt(lc.A*lc.A+lc.B*lc.B))
 	a_ := lc.A / c
 	b_ := lc.B / c
 
-	t := findGamutIntersection(a_, b_, lc.L, c, L0)
+	lC, cC := s.cusp(a_, b_)
+	t := s.intersect(a_, b_, lc.L, c, L0, lC, cC)
 	lClipped := L0*(1-t) + t*lc.L
 	cClipped := t * c
 
@@ -40,16 +80,16 @@ func GamutClipProjectToL0(lc Lab, L0 float64) Lab {
 	}
 }
 
-func GamutClipProjectToLCusp(lc Lab) Lab {
+func (s *RGBSpace) GamutClipProjectToLCusp(lc Lab) Lab {
 	c := max(eps, math.Sqrt(lc.A*lc.A+lc.B*lc.B))
 	a_ := lc.A / c
 	b_ := lc.B / c
 
-	lC, cC := findCusp(a_, b_)
+	lC, cC := s.cusp(a_, b_)
 
 	l0 := lC
 
-	t := findGamutIntersectionWithCusp(a_, b_, lc.L, c, l0, lC, cC)
+	t := s.intersect(a_, b_, lc.L, c, l0, lC, cC)
 
 	lClipped := l0*(1-t) + t*lc.L
 	cClipped := t * c
@@ -62,13 +102,13 @@ func GamutClipProjectToLCusp(lc Lab) Lab {
 	}
 }
 
-func GamutClipperAdaptive05(alpha float64) Clipper {
+func (s *RGBSpace) GamutClipperAdaptive05(alpha float64) Clipper {
 	return func(lc Lab) Lab {
-		return GamutClipAdaptive05(lc, alpha)
+		return s.GamutClipAdaptive05(lc, alpha)
 	}
 }
 
-func GamutClipAdaptive05(lc Lab, alpha float64) Lab {
+func (s *RGBSpace) GamutClipAdaptive05(lc Lab, alpha float64) Lab {
 	c := max(eps, math.Sqrt(lc.A*lc.A+lc.B*lc.B))
 	a_ := lc.A / c
 	b_ := lc.B / c
@@ -77,7 +117,8 @@ func GamutClipAdaptive05(lc Lab, alpha float64) Lab {
 	e1 := 0.5 + math.Abs(ld) + alpha*c
 	L0 := 0.5 * (1 + sgn(ld)*(e1-math.Sqrt(e1*e1-2*math.Abs(ld))))
 
-	t := findGamutIntersection(a_, b_, lc.L, c, L0)
+	lC, cC := s.cusp(a_, b_)
+	t := s.intersect(a_, b_, lc.L, c, L0, lC, cC)
 	lClipped := L0*(1-t) + t*lc.L
 	cClipped := t * c
 
@@ -89,18 +130,18 @@ func GamutClipAdaptive05(lc Lab, alpha float64) Lab {
 	}
 }
 
-func GamutClipperAdaptiveLCusp(alpha float64) Clipper {
+func (s *RGBSpace) GamutClipperAdaptiveLCusp(alpha float64) Clipper {
 	return func(lc Lab) Lab {
-		return GamutClipAdaptiveLCusp(lc, alpha)
+		return s.GamutClipAdaptiveLCusp(lc, alpha)
 	}
 }
 
-func GamutClipAdaptiveLCusp(lc Lab, alpha float64) Lab {
+func (s *RGBSpace) GamutClipAdaptiveLCusp(lc Lab, alpha float64) Lab {
 	c := max(eps, math.Sqrt(lc.A*lc.A+lc.B*lc.B))
 	a_ := lc.A / c
 	b_ := lc.B / c
 
-	lC, cC := findCusp(a_, b_)
+	lC, cC := s.cusp(a_, b_)
 
 	ld := lc.L - lC
 	var k float64
@@ -113,7 +154,7 @@ func GamutClipAdaptiveLCusp(lc Lab, alpha float64) Lab {
 	e1 := 0.5*k + math.Abs(ld) + alpha*c/k
 	l0 := lC + 0.5*(sgn(ld)*(e1-math.Sqrt(e1*e1-2*k*math.Abs(ld))))
 
-	t := findGamutIntersectionWithCusp(a_, b_, lc.L, c, l0, lC, cC)
+	t := s.intersect(a_, b_, lc.L, c, l0, lC, cC)
 	lClipped := l0*(1-t) + t*lc.L
 	cClipped := t * c
 
@@ -144,16 +185,11 @@ func sgn(x float64) float64 {
 	return 0
 }
 
-// findGamutIntersection finds intersection of the line defined by
+// findGamutIntersectionWithCusp finds intersection of the line defined by
 // L = L0 * (1 - t) + t * L1
 // C = t * C1
+// with the sRGB gamut, given its cusp for the hue
 // a and b must be normalized so a^2 + b^2 == 1
-func findGamutIntersection(a, b, L1, C1, L0 float64) float64 {
-	// find the cusp of the gamut triangle
-	lC, cC := findCusp(a, b)
-	return findGamutIntersectionWithCusp(a, b, L1, C1, L0, lC, cC)
-}
-
 func findGamutIntersectionWithCusp(a, b, L1, C1, L0, lC, cC float64) float64 {
 	// find the intersection for upper and lower half separately
 	var t float64
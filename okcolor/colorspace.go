@@ -24,10 +24,16 @@ func labConvert(c color.Color) color.Color {
 		return c
 	case LCh:
 		return lc.Lab()
+	case Okhsv:
+		return lc.Lab()
+	case Okhsl:
+		return lc.Lab()
 	}
 
-	col := linearRGBAConvert(c).(LinearRGBA)
+	return linearToLab(linearRGBAConvert(c).(LinearRGBA))
+}
 
+func linearToLab(col LinearRGBA) Lab {
 	var l, m, s float64
 	l = math.Cbrt(0.4122214708*col.R + 0.5363325363*col.G + 0.0514459929*col.B)
 	m = math.Cbrt(0.2119034982*col.R + 0.6806995451*col.G + 0.1073969566*col.B)
@@ -0,0 +1,147 @@
+// based on:
+// http://www.brucelindbloom.com/index.html?ColorDifference.html
+// https://hajim.rochester.edu/ece/sites/gsharma/ciede2000/ciede2000noteCRNA.pdf
+// https://www.compuphase.com/cmetric.htm
+
+package okcolor
+
+import (
+	"image/color"
+	"math"
+)
+
+// DeltaEOK is the Euclidean distance between two Oklab colors.
+func DeltaEOK(x, y Lab) float64 {
+	dL, da, db := x.L-y.L, x.A-y.A, x.B-y.B
+	return math.Sqrt(dL*dL + da*da + db*db)
+}
+
+// DeltaEOKWeighted is the Euclidean distance between two Oklab colors with
+// lightness differences scaled by wL relative to chroma and hue differences.
+func DeltaEOKWeighted(x, y Lab, wL float64) float64 {
+	dL, da, db := (x.L-y.L)*wL, x.A-y.A, x.B-y.B
+	return math.Sqrt(dL*dL + da*da + db*db)
+}
+
+// DeltaE76 is the Euclidean distance between two CIELAB colors, given as
+// L, a, b coordinates with L in [0, 100].
+func DeltaE76(x, y [3]float64) float64 {
+	dL, da, db := x[0]-y[0], x[1]-y[1], x[2]-y[2]
+	return math.Sqrt(dL*dL + da*da + db*db)
+}
+
+// DeltaE94 is the CIE94 difference between a reference CIELAB color x and a
+// sample y, using the graphic arts weights.
+func DeltaE94(x, y [3]float64) float64 {
+	const (
+		kL = 1
+		k1 = 0.045
+		k2 = 0.015
+	)
+
+	dL := x[0] - y[0]
+	c1 := math.Hypot(x[1], x[2])
+	c2 := math.Hypot(y[1], y[2])
+	dC := c1 - c2
+	da, db := x[1]-y[1], x[2]-y[2]
+	dH2 := max(da*da+db*db-dC*dC, 0)
+
+	sC := 1 + k1*c1
+	sH := 1 + k2*c1
+	tL, tC := dL/kL, dC/sC
+	return math.Sqrt(tL*tL + tC*tC + dH2/(sH*sH))
+}
+
+// DeltaE2000 is the CIEDE2000 difference between two CIELAB colors.
+func DeltaE2000(x, y [3]float64) float64 {
+	const pow25_7 = 6103515625 // 25^7
+
+	L1, a1, b1 := x[0], x[1], x[2]
+	L2, a2, b2 := y[0], y[1], y[2]
+
+	cBar := (math.Hypot(a1, b1) + math.Hypot(a2, b2)) / 2
+	cBar7 := math.Pow(cBar, 7)
+	g := 0.5 * (1 - math.Sqrt(cBar7/(cBar7+pow25_7)))
+
+	a1p, a2p := (1+g)*a1, (1+g)*a2
+	c1p, c2p := math.Hypot(a1p, b1), math.Hypot(a2p, b2)
+	h1p, h2p := hueAngle(b1, a1p), hueAngle(b2, a2p)
+
+	dLp := L2 - L1
+	dCp := c2p - c1p
+	var dhp float64
+	if c1p*c2p != 0 {
+		dhp = h2p - h1p
+		if dhp > 180 {
+			dhp -= 360
+		} else if dhp < -180 {
+			dhp += 360
+		}
+	}
+	dHp := 2 * math.Sqrt(c1p*c2p) * math.Sin(radians(dhp/2))
+
+	lBarP := (L1 + L2) / 2
+	cBarP := (c1p + c2p) / 2
+	hBarP := h1p + h2p
+	if c1p*c2p != 0 {
+		if math.Abs(h1p-h2p) > 180 {
+			if hBarP < 360 {
+				hBarP += 360
+			} else {
+				hBarP -= 360
+			}
+		}
+		hBarP /= 2
+	}
+
+	t := 1 - 0.17*math.Cos(radians(hBarP-30)) +
+		0.24*math.Cos(radians(2*hBarP)) +
+		0.32*math.Cos(radians(3*hBarP+6)) -
+		0.20*math.Cos(radians(4*hBarP-63))
+	dTheta := 30 * math.Exp(-math.Pow((hBarP-275)/25, 2))
+	cBarP7 := math.Pow(cBarP, 7)
+	rC := 2 * math.Sqrt(cBarP7/(cBarP7+pow25_7))
+	l50 := (lBarP - 50) * (lBarP - 50)
+	sL := 1 + 0.015*l50/math.Sqrt(20+l50)
+	sC := 1 + 0.045*cBarP
+	sH := 1 + 0.015*cBarP*t
+	rT := -math.Sin(radians(2*dTheta)) * rC
+
+	tL, tC, tH := dLp/sL, dCp/sC, dHp/sH
+	return math.Sqrt(tL*tL + tC*tC + tH*tH + rT*tC*tH)
+}
+
+// hueAngle returns the angle of (a, b) in degrees, in [0, 360).
+func hueAngle(b, a float64) float64 {
+	if (a == 0) && (b == 0) {
+		return 0
+	}
+	h := math.Atan2(b, a) * 180 / math.Pi
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Redmean is a low cost approximation of perceived differences between sRGB
+// colors, weighting channels by the mean red level. The result is on the
+// scale of 8-bit channel values.
+func Redmean(x, y color.Color) float64 {
+	r1, g1, b1, _ := x.RGBA()
+	r2, g2, b2, _ := y.RGBA()
+	return RedmeanRGB(
+		[3]float64{float64(r1) / 0x101, float64(g1) / 0x101, float64(b1) / 0x101},
+		[3]float64{float64(r2) / 0x101, float64(g2) / 0x101, float64(b2) / 0x101},
+	)
+}
+
+// RedmeanRGB is Redmean on sRGB coordinates in [0, 255].
+func RedmeanRGB(x, y [3]float64) float64 {
+	rBar := (x[0] + y[0]) / 2
+	dr, dg, db := x[0]-y[0], x[1]-y[1], x[2]-y[2]
+	return math.Sqrt((2+rBar/256)*dr*dr + 4*dg*dg + (2+(255-rBar)/256)*db*db)
+}
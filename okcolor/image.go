@@ -5,24 +5,300 @@ import (
 	"image/color"
 )
 
-type Image struct {
-	// Pix holds the image's pixels, as palette indices. The pixel at
-	// (x, y) starts at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*1].
-	Pix []uint8
-	// Stride is the Pix stride (in bytes) between vertically adjacent pixels.
+// LabImage is an in-memory image of Oklab colors. Pixels are kept as Lab
+// values, so processing in Oklab needs no conversion or interface allocation
+// per pixel when done through LabAt, SetLab or Row.
+type LabImage struct {
+	// Pix holds the image's pixels. The pixel at (x, y) is
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []Lab
+	// Stride is the Pix stride (in pixels) between vertically adjacent pixels.
 	Stride int
 	// Rect is the image's bounds.
 	Rect image.Rectangle
-	// Palette is the image's palette.
-	Palette color.Palette
+	// Clip maps out of gamut colors back into sRGB when pixels are read as
+	// RGB colors. If nil, GamutClipperAdaptive05(0.05) is used, as for Lab.
+	Clip Clipper
 }
 
-// bytes per pixel: r, g, b, a float64 = 4 * 8 = 32
+func NewLabImage(r image.Rectangle) *LabImage {
+	return &LabImage{
+		Pix:    make([]Lab, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// NewLabImageFromRGBA64 converts every pixel of src to Oklab.
+func NewLabImageFromRGBA64(src *image.RGBA64) *LabImage {
+	dst := NewLabImage(src.Rect)
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		row := dst.Row(y)
+		for i := range row {
+			row[i] = linearToLab(sRGBToLinearRGB(src.RGBA64At(src.Rect.Min.X+i, y)))
+		}
+	}
+	return dst
+}
+
+func (p *LabImage) ColorModel() color.Model { return LabModel }
+
+func (p *LabImage) Bounds() image.Rectangle { return p.Rect }
+
+func (p *LabImage) At(x, y int) color.Color {
+	return p.LabAt(x, y)
+}
+
+func (p *LabImage) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	return labToRGBA64(p.Pix[p.PixOffset(x, y)], p.clip())
+}
+
+func (p *LabImage) LabAt(x, y int) Lab {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return Lab{}
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// PixOffset returns the index of the element of Pix that corresponds to the
+// pixel at (x, y).
+func (p *LabImage) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+// Row returns the pixels of row y within the image bounds, sharing storage
+// with the image, or nil if y is out of bounds.
+func (p *LabImage) Row(y int) []Lab {
+	if (y < p.Rect.Min.Y) || (y >= p.Rect.Max.Y) {
+		return nil
+	}
+	i := p.PixOffset(p.Rect.Min.X, y)
+	return p.Pix[i : i+p.Rect.Dx() : i+p.Rect.Dx()]
+}
+
+func (p *LabImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = labConvert(c).(Lab)
+}
+
+func (p *LabImage) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = linearToLab(sRGBToLinearRGB(c))
+}
+
+func (p *LabImage) SetLab(x, y int, c Lab) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = c
+}
+
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *LabImage) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be
+	// inside either r1 or r2 if the intersection is empty. Without explicitly
+	// checking for this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &LabImage{Clip: p.Clip}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &LabImage{
+		Pix:    p.Pix[i:],
+		Stride: p.Stride,
+		Rect:   r,
+		Clip:   p.Clip,
+	}
+}
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *LabImage) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for _, c := range p.Row(y) {
+			if c.Alpha != 0xFFFF {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RGBA64 converts the image back to sRGB, clipping out of gamut colors with
+// the image's clipper.
+func (p *LabImage) RGBA64() *image.RGBA64 {
+	dst := image.NewRGBA64(p.Rect)
+	clip := p.clip()
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for i, c := range p.Row(y) {
+			dst.SetRGBA64(p.Rect.Min.X+i, y, labToRGBA64(c, clip))
+		}
+	}
+	return dst
+}
+
+func (p *LabImage) clip() Clipper {
+	if p.Clip == nil {
+		return GamutClipperAdaptive05(0.05)
+	}
+	return p.Clip
+}
+
+// LinearRGBAImage is an in-memory image of linear sRGB colors. Pixels are
+// kept as LinearRGBA values, so processing in linear light needs no
+// conversion or interface allocation per pixel when done through
+// LinearRGBAAt, SetLinearRGBA or Row.
+type LinearRGBAImage struct {
+	// Pix holds the image's pixels. The pixel at (x, y) is
+	// Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)].
+	Pix []LinearRGBA
+	// Stride is the Pix stride (in pixels) between vertically adjacent pixels.
+	Stride int
+	// Rect is the image's bounds.
+	Rect image.Rectangle
+	// Clip maps out of gamut colors back into sRGB when pixels are read as
+	// RGB colors. If nil, LinearRGBAGamutClipperAdaptive05(0.05) is used, as
+	// for LinearRGBA.
+	Clip LinearRGBAClipper
+}
+
+func NewLinearRGBAImage(r image.Rectangle) *LinearRGBAImage {
+	return &LinearRGBAImage{
+		Pix:    make([]LinearRGBA, r.Dx()*r.Dy()),
+		Stride: r.Dx(),
+		Rect:   r,
+	}
+}
+
+// NewLinearRGBAImageFromRGBA64 converts every pixel of src to linear sRGB.
+func NewLinearRGBAImageFromRGBA64(src *image.RGBA64) *LinearRGBAImage {
+	dst := NewLinearRGBAImage(src.Rect)
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		row := dst.Row(y)
+		for i := range row {
+			row[i] = sRGBToLinearRGB(src.RGBA64At(src.Rect.Min.X+i, y))
+		}
+	}
+	return dst
+}
+
+func (p *LinearRGBAImage) ColorModel() color.Model { return LinearRGBAModel }
+
+func (p *LinearRGBAImage) Bounds() image.Rectangle { return p.Rect }
+
+func (p *LinearRGBAImage) At(x, y int) color.Color {
+	return p.LinearRGBAAt(x, y)
+}
+
+func (p *LinearRGBAImage) RGBA64At(x, y int) color.RGBA64 {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return color.RGBA64{}
+	}
+	return linearRGBToSRGB(p.clip()(p.Pix[p.PixOffset(x, y)]))
+}
+
+func (p *LinearRGBAImage) LinearRGBAAt(x, y int) LinearRGBA {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return LinearRGBA{}
+	}
+	return p.Pix[p.PixOffset(x, y)]
+}
+
+// PixOffset returns the index of the element of Pix that corresponds to the
+// pixel at (x, y).
+func (p *LinearRGBAImage) PixOffset(x, y int) int {
+	return (y-p.Rect.Min.Y)*p.Stride + (x - p.Rect.Min.X)
+}
+
+// Row returns the pixels of row y within the image bounds, sharing storage
+// with the image, or nil if y is out of bounds.
+func (p *LinearRGBAImage) Row(y int) []LinearRGBA {
+	if (y < p.Rect.Min.Y) || (y >= p.Rect.Max.Y) {
+		return nil
+	}
+	i := p.PixOffset(p.Rect.Min.X, y)
+	return p.Pix[i : i+p.Rect.Dx() : i+p.Rect.Dx()]
+}
+
+func (p *LinearRGBAImage) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = linearRGBAConvert(c).(LinearRGBA)
+}
+
+func (p *LinearRGBAImage) SetRGBA64(x, y int, c color.RGBA64) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = sRGBToLinearRGB(c)
+}
+
+func (p *LinearRGBAImage) SetLinearRGBA(x, y int, c LinearRGBA) {
+	if !(image.Point{x, y}.In(p.Rect)) {
+		return
+	}
+	p.Pix[p.PixOffset(x, y)] = c
+}
 
-func NewImage(r image.Rectangle) *Image {
-	return &Image{
-		Pix:    make([]uint8, r.Dx()*r.Dy()*32),
-		Stride: 32 * r.Dx(),
+// SubImage returns an image representing the portion of the image p visible
+// through r. The returned value shares pixels with the original image.
+func (p *LinearRGBAImage) SubImage(r image.Rectangle) image.Image {
+	r = r.Intersect(p.Rect)
+	// If r1 and r2 are Rectangles, r1.Intersect(r2) is not guaranteed to be
+	// inside either r1 or r2 if the intersection is empty. Without explicitly
+	// checking for this, the Pix[i:] expression below can panic.
+	if r.Empty() {
+		return &LinearRGBAImage{Clip: p.Clip}
+	}
+	i := p.PixOffset(r.Min.X, r.Min.Y)
+	return &LinearRGBAImage{
+		Pix:    p.Pix[i:],
+		Stride: p.Stride,
 		Rect:   r,
+		Clip:   p.Clip,
 	}
 }
+
+// Opaque scans the entire image and reports whether it is fully opaque.
+func (p *LinearRGBAImage) Opaque() bool {
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for _, c := range p.Row(y) {
+			if c.A != 0xFFFF {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// RGBA64 converts the image back to sRGB, clipping out of gamut colors with
+// the image's clipper.
+func (p *LinearRGBAImage) RGBA64() *image.RGBA64 {
+	dst := image.NewRGBA64(p.Rect)
+	clip := p.clip()
+	for y := p.Rect.Min.Y; y < p.Rect.Max.Y; y++ {
+		for i, c := range p.Row(y) {
+			dst.SetRGBA64(p.Rect.Min.X+i, y, linearRGBToSRGB(clip(c)))
+		}
+	}
+	return dst
+}
+
+func (p *LinearRGBAImage) clip() LinearRGBAClipper {
+	if p.Clip == nil {
+		return LinearRGBAGamutClipperAdaptive05(0.05)
+	}
+	return p.Clip
+}
+
+func labToRGBA64(lc Lab, clip Clipper) color.RGBA64 {
+	return linearRGBToSRGB(lc.LinearRGBA(clip))
+}
@@ -15,8 +15,11 @@ type LinearRGBA struct {
 var LinearRGBAModel = color.ModelFunc(linearRGBAConvert)
 
 func linearRGBAConvert(c color.Color) color.Color {
-	if _, ok := c.(LinearRGBA); ok {
+	switch lc := c.(type) {
+	case LinearRGBA:
 		return c
+	case xyzColor:
+		return lc.XYZ().LinearRGBA()
 	}
 
 	return sRGBToLinearRGB(color.RGBA64Model.Convert(c).(color.RGBA64))
@@ -30,7 +33,16 @@ func (lc LinearRGBA) RGBA() (uint32, uint32, uint32, uint32) {
 	return lc.ClippedRGBA(LinearRGBAGamutClipperAdaptive05(0.05))
 }
 
+// linearRGBToSRGB clamps the channels to the alpha first, as clipping leaves
+// rounding errors around the gamut boundary that would otherwise wrap around
+// or break premultiplication.
 func linearRGBToSRGB(lc LinearRGBA) color.RGBA64 {
+	hi := 1.0
+	if lc.A != 0xFFFF {
+		hi = toLinear(float64(lc.A) / 65535)
+	}
+	lc.R, lc.G, lc.B = clamp(lc.R, 0, hi), clamp(lc.G, 0, hi), clamp(lc.B, 0, hi)
+
 	return color.RGBA64{
 		R: uint16(fromLinear(lc.R) * 65535),
 		G: uint16(fromLinear(lc.G) * 65535),
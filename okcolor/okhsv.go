@@ -0,0 +1,265 @@
+// based on:
+// https://bottosson.github.io/posts/colorpicker/
+
+package okcolor
+
+import (
+	"image/color"
+	"math"
+)
+
+// achromaticChroma is the chroma under which colors are taken as grays, as
+// rounding leaves neutral sRGB colors with a tiny chroma that Okhsl would
+// turn into a large saturation near white.
+const achromaticChroma = 1e-6
+
+// Okhsv is a hue, saturation and value color space built on Oklab, where
+// S and V are in [0, 1] for the whole sRGB gamut, like HSV. As the gamut is
+// approximated, S can exceed 1 by about 1% for some blue and purple colors.
+type Okhsv struct {
+	H     float64 // hue, the OkLCh hue in radians
+	S     float64 // saturation
+	V     float64 // value
+	Alpha uint16  // alpha
+}
+
+var OkhsvModel = color.ModelFunc(okhsvConvert)
+
+func okhsvConvert(c color.Color) color.Color {
+	if _, ok := c.(Okhsv); ok {
+		return c
+	}
+	return labConvert(c).(Lab).Okhsv()
+}
+
+func (hc Okhsv) RGBA() (uint32, uint32, uint32, uint32) {
+	return hc.Lab().RGBA()
+}
+
+func (hc Okhsv) LinearRGBA(clipFunc Clipper) LinearRGBA {
+	return hc.Lab().LinearRGBA(clipFunc)
+}
+
+func (hc Okhsv) Lab() Lab {
+	if hc.V <= 0 {
+		return Lab{Alpha: hc.Alpha}
+	}
+
+	a, b := math.Cos(hc.H), math.Sin(hc.H)
+	sMax, tMax := cuspST(a, b)
+	const s0 = 0.5
+	k := 1 - s0/sMax
+
+	// L and C on the line from black to the cusp-side boundary, at V = 1
+	div := s0 + tMax - tMax*k*hc.S
+	lV := 1 - hc.S*s0/div
+	cV := hc.S * tMax * s0 / div
+
+	L := hc.V * lV
+	C := hc.V * cV
+
+	// compensate for both the toe and the curved top of the gamut triangle
+	lVt := toeInv(lV)
+	cVt := cV * lVt / lV
+	lNew := toeInv(L)
+	C = C * lNew / L
+	L = lNew
+
+	scale := rgbScale(lVt, cVt, a, b)
+	L *= scale
+	C *= scale
+
+	return Lab{L: L, A: C * a, B: C * b, Alpha: hc.Alpha}
+}
+
+func (lc Lab) Okhsv() Okhsv {
+	C := math.Sqrt(lc.A*lc.A + lc.B*lc.B)
+	if (lc.L <= 0) || (C < achromaticChroma) {
+		return Okhsv{V: toe(max(lc.L, 0)), Alpha: lc.Alpha}
+	}
+
+	a, b := lc.A/C, lc.B/C
+	sMax, tMax := cuspST(a, b)
+	const s0 = 0.5
+	k := 1 - s0/sMax
+
+	// project onto the line from black to the cusp-side boundary
+	t := tMax / (C + lc.L*tMax)
+	lV := t * lc.L
+	cV := t * C
+
+	lVt := toeInv(lV)
+	cVt := cV * lVt / lV
+
+	scale := rgbScale(lVt, cVt, a, b)
+	L := lc.L / scale
+	C /= scale
+
+	C = C * toe(L) / L
+	L = toe(L)
+
+	return Okhsv{
+		H:     math.Atan2(lc.B, lc.A),
+		S:     (s0 + tMax) * cV / (tMax*s0 + tMax*k*cV),
+		V:     L / lV,
+		Alpha: lc.Alpha,
+	}
+}
+
+// Okhsl is a hue, saturation and lightness color space built on Oklab, where
+// S and L are in [0, 1] for the whole sRGB gamut, like HSL, with the same
+// approximation as Okhsv. L is the Oklab lightness remapped to be closer to
+// CIELAB's.
+type Okhsl struct {
+	H     float64 // hue, the OkLCh hue in radians
+	S     float64 // saturation
+	L     float64 // lightness
+	Alpha uint16  // alpha
+}
+
+var OkhslModel = color.ModelFunc(okhslConvert)
+
+func okhslConvert(c color.Color) color.Color {
+	if _, ok := c.(Okhsl); ok {
+		return c
+	}
+	return labConvert(c).(Lab).Okhsl()
+}
+
+func (hc Okhsl) RGBA() (uint32, uint32, uint32, uint32) {
+	return hc.Lab().RGBA()
+}
+
+func (hc Okhsl) LinearRGBA(clipFunc Clipper) LinearRGBA {
+	return hc.Lab().LinearRGBA(clipFunc)
+}
+
+// okhslMid is the saturation of the C_mid chroma, where the two segments of
+// the saturation curve meet.
+const okhslMid = 0.8
+
+func (hc Okhsl) Lab() Lab {
+	if hc.L >= 1 {
+		return Lab{L: 1, Alpha: hc.Alpha}
+	} else if hc.L <= 0 {
+		return Lab{Alpha: hc.Alpha}
+	}
+
+	a, b := math.Cos(hc.H), math.Sin(hc.H)
+	L := toeInv(hc.L)
+	c0, cMid, cMax := chromaStops(L, a, b)
+
+	var C float64
+	if hc.S < okhslMid {
+		t := hc.S / okhslMid
+		k1 := okhslMid * c0
+		k2 := 1 - k1/cMid
+		C = t * k1 / (1 - k2*t)
+	} else {
+		t := (hc.S - okhslMid) / (1 - okhslMid)
+		k0 := cMid
+		k1 := (1 - okhslMid) * cMid * cMid / (okhslMid * okhslMid * c0)
+		k2 := 1 - k1/(cMax-cMid)
+		C = k0 + t*k1/(1-k2*t)
+	}
+
+	return Lab{L: L, A: C * a, B: C * b, Alpha: hc.Alpha}
+}
+
+func (lc Lab) Okhsl() Okhsl {
+	C := math.Sqrt(lc.A*lc.A + lc.B*lc.B)
+	if (lc.L <= 0) || (lc.L >= 1) || (C < achromaticChroma) {
+		return Okhsl{L: toe(clamp(lc.L, 0, 1)), Alpha: lc.Alpha}
+	}
+
+	a, b := lc.A/C, lc.B/C
+	c0, cMid, cMax := chromaStops(lc.L, a, b)
+
+	var s float64
+	if C < cMid {
+		k1 := okhslMid * c0
+		k2 := 1 - k1/cMid
+		t := C / (k1 + k2*C)
+		s = t * okhslMid
+	} else {
+		k0 := cMid
+		k1 := (1 - okhslMid) * cMid * cMid / (okhslMid * okhslMid * c0)
+		k2 := 1 - k1/(cMax-cMid)
+		t := (C - k0) / (k1 + k2*(C-k0))
+		s = okhslMid + (1-okhslMid)*t
+	}
+
+	return Okhsl{
+		H:     math.Atan2(lc.B, lc.A),
+		S:     s,
+		L:     toe(lc.L),
+		Alpha: lc.Alpha,
+	}
+}
+
+const (
+	toeK1 = 0.206
+	toeK2 = 0.03
+	toeK3 = (1 + toeK1) / (1 + toeK2)
+)
+
+// toe maps Oklab lightness to a lightness estimate closer to CIELAB's L*.
+func toe(x float64) float64 {
+	return 0.5 * (toeK3*x - toeK1 + math.Sqrt((toeK3*x-toeK1)*(toeK3*x-toeK1)+4*toeK2*toeK3*x))
+}
+
+func toeInv(x float64) float64 {
+	return (x*x + toeK1*x) / (toeK3 * (x + toeK2))
+}
+
+// cuspST returns the slopes of the lower (S = C/L) and upper (T = C/(1-L))
+// edges of the gamut triangle for a hue.
+// a and b must be normalized so a^2 + b^2 == 1
+func cuspST(a, b float64) (float64, float64) {
+	lCusp, cCusp := findCusp(a, b)
+	return cCusp / lCusp, cCusp / (1 - lCusp)
+}
+
+// rgbScale returns the factor that brings L and C of a color on the straight
+// gamut triangle edge onto the real, curved, sRGB gamut boundary.
+func rgbScale(L, C, a, b float64) float64 {
+	rgb := Lab{L: L, A: a * C, B: b * C}.LinearRGBA(nil)
+	return math.Cbrt(1 / max(rgb.R, rgb.G, rgb.B, 0))
+}
+
+// midST is a polynomial fit of S and T for a smooth approximation of the
+// gamut, used to place the middle saturation stop of Okhsl.
+// a and b must be normalized so a^2 + b^2 == 1
+func midST(a, b float64) (float64, float64) {
+	s := 0.11516993 + 1/(7.44778970+4.15901240*b+
+		a*(-2.19557347+1.75198401*b+
+			a*(-2.13704948-10.02301043*b+
+				a*(-4.24894561+5.38770819*b+4.69891013*a))))
+	t := 0.11239642 + 1/(1.61320320-0.68124379*b+
+		a*(0.40370612+0.90148123*b+
+			a*(-0.27087943+0.61223990*b+
+				a*(0.00299215-0.45399568*b-0.14661872*a))))
+	return s, t
+}
+
+// chromaStops returns, at lightness L for a hue, the chroma that sets the
+// slope of the Okhsl saturation curve near gray and the chromas of
+// saturations 0.8 and 1.
+// a and b must be normalized so a^2 + b^2 == 1
+func chromaStops(L, a, b float64) (float64, float64, float64) {
+	lCusp, cCusp := findCusp(a, b)
+	cMax := findGamutIntersectionWithCusp(a, b, L, 1, L, lCusp, cCusp)
+	sMax, tMax := cCusp/lCusp, cCusp/(1-lCusp)
+
+	// scale the mid stop so it stays inside the real gamut
+	k := cMax / min(L*sMax, (1-L)*tMax)
+
+	sMid, tMid := midST(a, b)
+	ca, cb := L*sMid, (1-L)*tMid
+	cMid := 0.9 * k * math.Sqrt(math.Sqrt(1/(1/(ca*ca*ca*ca)+1/(cb*cb*cb*cb))))
+
+	ca, cb = L*0.4, (1-L)*0.8
+	c0 := math.Sqrt(1 / (1/(ca*ca) + 1/(cb*cb)))
+
+	return c0, cMid, cMax
+}
@@ -0,0 +1,393 @@
+// based on:
+// http://www.brucelindbloom.com/index.html?Eqn_RGB_XYZ_Matrix.html
+
+package okcolor
+
+import (
+	"image/color"
+	"math"
+)
+
+// RGBSpace is an RGB color space, given by the chromaticities of its
+// primaries, its white point and its transfer function. Colors of spaces with
+// a D50 white point are adapted to and from the D65 XYZ of XYZ with the
+// Bradford transform.
+type RGBSpace struct {
+	Name string
+
+	toXYZ    [3][3]float64 // linear RGB to XYZ
+	fromXYZ  [3][3]float64 // XYZ to linear RGB
+	fromSRGB [3][3]float64 // linear sRGB to linear RGB
+
+	encode func(float64) float64 // linear to encoded
+	decode func(float64) float64 // encoded to linear
+
+	// cusp and intersect locate the gamut boundary in Oklab for the
+	// clippers, with the same arguments as findCusp and
+	// findGamutIntersectionWithCusp
+	cusp      func(a, b float64) (float64, float64)
+	intersect func(a, b, L1, C1, L0, lC, cC float64) float64
+
+	model       color.Model
+	linearModel color.Model
+}
+
+var (
+	d65White = [3]float64{0.95047, 1, 1.08883}
+
+	bradfordD65ToD50 = [3][3]float64{
+		{1.0478112, 0.0228866, -0.0501270},
+		{0.0295424, 0.9904844, -0.0170491},
+		{-0.0092345, 0.0150436, 0.7521316},
+	}
+
+	linearSRGBToXYZ = [3][3]float64{
+		{0.4124564, 0.3575761, 0.1804375},
+		{0.2126729, 0.7151522, 0.0721750},
+		{0.0193339, 0.1191920, 0.9503041},
+	}
+
+	bradfordD50ToD65 = [3][3]float64{
+		{0.9555766, -0.0230393, 0.0631636},
+		{-0.0282895, 1.0099416, 0.0210077},
+		{0.0122982, -0.0204830, 1.3299098},
+	}
+)
+
+var (
+	// SRGB is the space of the LinearRGBA and Lab conversions. Its clippers are
+	// the package level ones.
+	SRGB = newSRGB()
+	// DisplayP3 has the primaries of DCI-P3 with a D65 white point and the
+	// sRGB transfer function, as used by Apple displays and phone cameras.
+	DisplayP3 = newRGBSpace("display-p3",
+		[3][2]float64{{0.680, 0.320}, {0.265, 0.690}, {0.150, 0.060}},
+		d65White, mirrored(fromLinear), mirrored(toLinear))
+	// Rec2020 is the ITU-R BT.2020 space of UHD television.
+	Rec2020 = newRGBSpace("rec2020",
+		[3][2]float64{{0.708, 0.292}, {0.170, 0.797}, {0.131, 0.046}},
+		d65White, mirrored(rec2020Encode), mirrored(rec2020Decode))
+	// AdobeRGB is the Adobe RGB (1998) space.
+	AdobeRGB = newRGBSpace("adobe-rgb",
+		[3][2]float64{{0.640, 0.330}, {0.210, 0.710}, {0.150, 0.060}},
+		d65White, mirrored(gammaEncode(563.0/256)), mirrored(gammaDecode(563.0/256)))
+	// ProPhotoRGB is the ROMM RGB space, with a D50 white point. Some of its
+	// primaries are imaginary colors.
+	ProPhotoRGB = newRGBSpace("prophoto-rgb",
+		[3][2]float64{{0.7347, 0.2653}, {0.1596, 0.8404}, {0.0366, 0.0001}},
+		d50White, mirrored(prophotoEncode), mirrored(prophotoDecode))
+)
+
+func newSRGB() *RGBSpace {
+	s := newRGBSpace("srgb",
+		[3][2]float64{{0.640, 0.330}, {0.300, 0.600}, {0.150, 0.060}},
+		d65White, mirrored(fromLinear), mirrored(toLinear))
+	s.cusp = findCusp
+	s.intersect = findGamutIntersectionWithCusp
+	return s
+}
+
+// newRGBSpace builds a space from the xy chromaticities of its red, green and
+// blue primaries, the XYZ of its white point and its transfer function.
+func newRGBSpace(name string, primaries [3][2]float64, white [3]float64, encode, decode func(float64) float64) *RGBSpace {
+	// XYZ of each primary with Y = 1, as columns
+	var p [3][3]float64
+	for i, xy := range primaries {
+		p[0][i] = xy[0] / xy[1]
+		p[1][i] = 1
+		p[2][i] = (1 - xy[0] - xy[1]) / xy[1]
+	}
+
+	// scale the primaries so they add up to white
+	scale := mulVec(invert(p), white)
+	var m [3][3]float64
+	for i := range m {
+		for j := range m[i] {
+			m[i][j] = p[i][j] * scale[j]
+		}
+	}
+	if white == d50White {
+		m = mulMat(bradfordD50ToD65, m)
+	}
+
+	s := &RGBSpace{
+		Name:    name,
+		toXYZ:   m,
+		fromXYZ: invert(m),
+		encode:  encode,
+		decode:  decode,
+	}
+	s.fromSRGB = mulMat(s.fromXYZ, linearSRGBToXYZ)
+	s.cusp = s.findCusp
+	s.intersect = s.findIntersection
+	s.model = color.ModelFunc(s.convert)
+	s.linearModel = color.ModelFunc(s.convertLinear)
+	return s
+}
+
+// Model converts colors to RGB colors of the space.
+func (s *RGBSpace) Model() color.Model { return s.model }
+
+// LinearModel converts colors to LinearRGB colors of the space.
+func (s *RGBSpace) LinearModel() color.Model { return s.linearModel }
+
+func (s *RGBSpace) convert(c color.Color) color.Color {
+	if rc, ok := c.(RGB); ok && (rc.Space == s) {
+		return c
+	}
+	return s.convertLinear(c).(LinearRGB).RGB()
+}
+
+func (s *RGBSpace) convertLinear(c color.Color) color.Color {
+	switch rc := c.(type) {
+	case LinearRGB:
+		if rc.Space == s {
+			return c
+		}
+	case RGB:
+		if rc.Space == s {
+			return rc.LinearRGB()
+		}
+	case LinearRGBA:
+		return s.fromLinearSRGB(rc)
+	}
+	return s.FromXYZ(xyzConvert(c).(XYZ))
+}
+
+func (s *RGBSpace) FromXYZ(xc XYZ) LinearRGB {
+	v := mulVec(s.fromXYZ, [3]float64{xc.X, xc.Y, xc.Z})
+	return LinearRGB{R: v[0], G: v[1], B: v[2], A: xc.Alpha, Space: s}
+}
+
+func (s *RGBSpace) fromLinearSRGB(lc LinearRGBA) LinearRGB {
+	v := mulVec(s.fromSRGB, [3]float64{lc.R, lc.G, lc.B})
+	return LinearRGB{R: v[0], G: v[1], B: v[2], A: lc.A, Space: s}
+}
+
+// FromLab converts an Oklab color to the space. If clipFunc is not nil and
+// the color is out of the gamut of the space, it is clipped first; the
+// clippers of the space, such as s.GamutClipPreserveChroma, map colors into
+// its gamut.
+func (s *RGBSpace) FromLab(lc Lab, clipFunc Clipper) LinearRGB {
+	res := s.fromLinearSRGB(lc.LinearRGBA(nil))
+	if (clipFunc != nil) && !res.inGamut() {
+		return s.fromLinearSRGB(clipFunc(lc).LinearRGBA(nil))
+	}
+	return res
+}
+
+// LinearRGB is a linear light color of an RGB space, with channels in
+// [0, 1] within its gamut. Like LinearRGBA, channels are premultiplied by
+// alpha. Space must not be nil.
+type LinearRGB struct {
+	R     float64
+	G     float64
+	B     float64
+	A     uint16
+	Space *RGBSpace
+}
+
+func (lc LinearRGB) RGBA() (uint32, uint32, uint32, uint32) {
+	return lc.XYZ().RGBA()
+}
+
+func (lc LinearRGB) XYZ() XYZ {
+	v := mulVec(lc.Space.toXYZ, [3]float64{lc.R, lc.G, lc.B})
+	return XYZ{X: v[0], Y: v[1], Z: v[2], Alpha: lc.A}
+}
+
+// RGB applies the transfer function of the space.
+func (lc LinearRGB) RGB() RGB {
+	s := lc.Space
+	return RGB{R: s.encode(lc.R), G: s.encode(lc.G), B: s.encode(lc.B), A: lc.A, Space: s}
+}
+
+func (lc LinearRGB) inGamut() bool {
+	return (lc.R >= 0) && (lc.R <= 1) && (lc.G >= 0) && (lc.G <= 1) && (lc.B >= 0) && (lc.B <= 1)
+}
+
+// RGB is a color of an RGB space encoded with its transfer function, with
+// channels in [0, 1] within its gamut. Space must not be nil.
+type RGB struct {
+	R     float64
+	G     float64
+	B     float64
+	A     uint16
+	Space *RGBSpace
+}
+
+func (rc RGB) RGBA() (uint32, uint32, uint32, uint32) {
+	return rc.LinearRGB().RGBA()
+}
+
+func (rc RGB) XYZ() XYZ {
+	return rc.LinearRGB().XYZ()
+}
+
+// LinearRGB removes the transfer function of the space.
+func (rc RGB) LinearRGB() LinearRGB {
+	s := rc.Space
+	return LinearRGB{R: s.decode(rc.R), G: s.decode(rc.G), B: s.decode(rc.B), A: rc.A, Space: s}
+}
+
+// mirrored extends a transfer function to negative values, which out of
+// gamut colors can have.
+func mirrored(f func(float64) float64) func(float64) float64 {
+	return func(x float64) float64 {
+		if x < 0 {
+			return -f(-x)
+		}
+		return f(x)
+	}
+}
+
+const (
+	rec2020Alpha = 1.09929682680944
+	rec2020Beta  = 0.018053968510807
+)
+
+func rec2020Encode(x float64) float64 {
+	if x < rec2020Beta {
+		return 4.5 * x
+	}
+	return rec2020Alpha*math.Pow(x, 0.45) - (rec2020Alpha - 1)
+}
+
+func rec2020Decode(x float64) float64 {
+	if x < 4.5*rec2020Beta {
+		return x / 4.5
+	}
+	return math.Pow((x+rec2020Alpha-1)/rec2020Alpha, 1/0.45)
+}
+
+func gammaEncode(gamma float64) func(float64) float64 {
+	return func(x float64) float64 {
+		return math.Pow(x, 1/gamma)
+	}
+}
+
+func gammaDecode(gamma float64) func(float64) float64 {
+	return func(x float64) float64 {
+		return math.Pow(x, gamma)
+	}
+}
+
+func prophotoEncode(x float64) float64 {
+	if x < 1.0/512 {
+		return 16 * x
+	}
+	return math.Pow(x, 1/1.8)
+}
+
+func prophotoDecode(x float64) float64 {
+	if x < 16.0/512 {
+		return x / 16
+	}
+	return math.Pow(x, 1.8)
+}
+
+func mulVec(m [3][3]float64, v [3]float64) [3]float64 {
+	return [3]float64{
+		m[0][0]*v[0] + m[0][1]*v[1] + m[0][2]*v[2],
+		m[1][0]*v[0] + m[1][1]*v[1] + m[1][2]*v[2],
+		m[2][0]*v[0] + m[2][1]*v[1] + m[2][2]*v[2],
+	}
+}
+
+func mulMat(x, y [3][3]float64) [3][3]float64 {
+	var res [3][3]float64
+	for i := range res {
+		for j := range res[i] {
+			for k := range 3 {
+				res[i][j] += x[i][k] * y[k][j]
+			}
+		}
+	}
+	return res
+}
+
+func invert(m [3][3]float64) [3][3]float64 {
+	// cofactors, transposed
+	res := [3][3]float64{
+		{m[1][1]*m[2][2] - m[1][2]*m[2][1], m[0][2]*m[2][1] - m[0][1]*m[2][2], m[0][1]*m[1][2] - m[0][2]*m[1][1]},
+		{m[1][2]*m[2][0] - m[1][0]*m[2][2], m[0][0]*m[2][2] - m[0][2]*m[2][0], m[0][2]*m[1][0] - m[0][0]*m[1][2]},
+		{m[1][0]*m[2][1] - m[1][1]*m[2][0], m[0][1]*m[2][0] - m[0][0]*m[2][1], m[0][0]*m[1][1] - m[0][1]*m[1][0]},
+	}
+	det := m[0][0]*res[0][0] + m[0][1]*res[1][0] + m[0][2]*res[2][0]
+	for i := range res {
+		for j := range res[i] {
+			res[i][j] /= det
+		}
+	}
+	return res
+}
+
+const (
+	// maxCuspSaturation bounds the search for the saturation of the cusp,
+	// well above that of the widest spaces
+	maxCuspSaturation  = 20
+	cuspSaturationStep = 0.05
+	boundaryIterations = 48
+	// boundaryTolerance absorbs rounding errors of colors on the boundary
+	boundaryTolerance = 1e-9
+)
+
+// findCusp finds lCusp and cCusp for a given hue, like the sRGB findCusp,
+// with the maximum saturation found by bisection instead of a polynomial fit.
+// a and b must be normalized so a^2 + b^2 == 1
+func (s *RGBSpace) findCusp(a, b float64) (float64, float64) {
+	// along a line from black, linear RGB values scale with L^3, so the
+	// maximum saturation S = C/L is where a channel first gets below 0 at L = 1
+	rgbAt := func(sat float64) LinearRGB {
+		return s.FromLab(Lab{L: 1, A: sat * a, B: sat * b}, nil)
+	}
+	negative := func(sat float64) bool {
+		rgb := rgbAt(sat)
+		return min(rgb.R, rgb.G, rgb.B) < 0
+	}
+
+	lo, hi := 0.0, cuspSaturationStep
+	for !negative(hi) && (hi < maxCuspSaturation) {
+		lo, hi = hi, hi+cuspSaturationStep
+	}
+	for range boundaryIterations {
+		if mid := (lo + hi) / 2; negative(mid) {
+			hi = mid
+		} else {
+			lo = mid
+		}
+	}
+
+	sCusp := lo
+	rgbAtMax := rgbAt(sCusp)
+	lCusp := math.Cbrt(1 / max(rgbAtMax.R, rgbAtMax.G, rgbAtMax.B))
+	return lCusp, lCusp * sCusp
+}
+
+// findIntersection finds, by bisection, the t where the line
+// L = L0 * (1 - t) + t * L1
+// C = t * C1
+// leaves the gamut, like findGamutIntersectionWithCusp. Colors already in
+// the gamut are left as they are, with t = 1.
+// a and b must be normalized so a^2 + b^2 == 1
+func (s *RGBSpace) findIntersection(a, b, L1, C1, L0, _, _ float64) float64 {
+	inside := func(t float64) bool {
+		L := L0*(1-t) + t*L1
+		C := t * C1
+		rgb := s.FromLab(Lab{L: L, A: C * a, B: C * b}, nil)
+		return (min(rgb.R, rgb.G, rgb.B) >= -boundaryTolerance) && (max(rgb.R, rgb.G, rgb.B) <= 1+boundaryTolerance)
+	}
+
+	if inside(1) {
+		return 1
+	}
+	lo, hi := 0.0, 1.0
+	for range boundaryIterations {
+		if mid := (lo + hi) / 2; inside(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return lo
+}
@@ -0,0 +1,108 @@
+package palcmd
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+
+	"picproc/palette"
+)
+
+type CalibrateCmd struct {
+	Chart   CalibrateChartCmd   `cmd:"" help:"Generate a test chart of a palette, to show on a device"`
+	Measure CalibrateMeasureCmd `cmd:"" help:"Extract the colors of a photographed or scanned test chart"`
+}
+
+// ChartFlags set the layout of a test chart. Measuring a chart requires the
+// same layout it was generated with.
+type ChartFlags struct {
+	Columns   int `help:"Patches per row (0 for a square grid)" default:"0"`
+	PatchSize int `help:"Patch size in pixels" default:"128"`
+	Border    int `help:"Frame width in pixels" default:"32"`
+}
+
+func (f ChartFlags) chart() palette.Chart {
+	return palette.Chart{Columns: f.Columns, PatchSize: f.PatchSize, Border: f.Border}
+}
+
+type CalibrateChartCmd struct {
+	Name string `arg:"" help:"Nominal palette name or file"`
+	Out  string `arg:"" help:"Destination PNG image" type:"path"`
+	ChartFlags
+}
+
+func (c *CalibrateChartCmd) Run() error {
+	pal, err := palette.LoadPalette(c.Name)
+	if err != nil {
+		return err
+	}
+
+	img, err := c.chart().Render(pal)
+	if err != nil {
+		return err
+	}
+	return savePNG(c.Out, img)
+}
+
+type CalibrateMeasureCmd struct {
+	Name   string `arg:"" help:"Nominal palette name or file the chart was generated from"`
+	Image  string `arg:"" help:"Photo or scan of the chart, cropped to the outer edge of its frame" type:"existingfile"`
+	Out    string `arg:"" help:"Destination palette file" type:"path"`
+	Format string `help:"Destination format. If not given, it is picked by file extension"`
+	ChartFlags
+}
+
+func (c *CalibrateMeasureCmd) Run() error {
+	pals, err := palette.LoadPalettes(c.Name)
+	if err != nil {
+		return err
+	}
+
+	img, err := loadImage(c.Image)
+	if err != nil {
+		return err
+	}
+
+	pal := palette.Concat(pals)
+	measured, err := c.chart().Measure(img, len(pal))
+	if err != nil {
+		return err
+	}
+
+	name := "measured"
+	if (len(pals) == 1) && (pals[0].Name != "") {
+		name = pals[0].Name + " " + name
+	}
+	return palette.SavePalettesToFile(c.Out, c.Format, []palette.Palette{{Name: name, Colors: measured}})
+}
+
+func loadImage(fileName string) (image.Image, error) {
+	imgFile, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("could not open image %q: %w", fileName, err)
+	}
+	defer imgFile.Close()
+
+	img, _, err := image.Decode(imgFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode image %q: %w", fileName, err)
+	}
+	return img, nil
+}
+
+func savePNG(fileName string, img image.Image) error {
+	outFile, err := os.Create(fileName)
+	if err != nil {
+		return fmt.Errorf("could not create image %q: %w", fileName, err)
+	}
+
+	if err = png.Encode(outFile, img); err != nil {
+		outFile.Close()
+		return fmt.Errorf("could not encode image %q: %w", fileName, err)
+	}
+	if err = outFile.Close(); err != nil {
+		return fmt.Errorf("could not save image %q: %w", fileName, err)
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package palcmd
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"picproc/okcolor"
+	"picproc/palette"
+)
+
+type CLICmd struct {
+	List      ListCmd      `cmd:"" help:"List built-in palettes and those found in the palette directories"`
+	Show      ShowCmd      `cmd:"" help:"Show the colors of a palette"`
+	Convert   ConvertCmd   `cmd:"" help:"Convert a palette to another format"`
+	Extract   ExtractCmd   `cmd:"" help:"Extract the palette of an image"`
+	Sort      SortCmd      `cmd:"" help:"Sort the colors of a palette"`
+	Merge     MergeCmd     `cmd:"" help:"Merge palettes into one, dropping duplicate colors"`
+	Reduce    ReduceCmd    `cmd:"" help:"Reduce a palette to fewer colors"`
+	Combine   CombineCmd   `cmd:"" help:"Combine palettes into a single file, keeping them separate"`
+	Calibrate CalibrateCmd `cmd:"" help:"Measure the colors a device really displays"`
+	Render    RenderCmd    `cmd:"" help:"Render a palette as an image of color chips"`
+	Generate  GenerateCmd  `cmd:"" help:"Generate a palette from color theory"`
+}
+
+// SearchFlags adds directories to the palette search path. It is meant to be
+// embedded in the root command: kong validates it before any subcommand, so
+// the path is set before palettes are loaded by subcommand validation.
+type SearchFlags struct {
+	PaletteDir []string `help:"Directory to look for palette files by name, searched before the user palette directory. Can be repeated" type:"existingdir"`
+}
+
+func (f *SearchFlags) Validate() error {
+	palette.SearchPath = append(slices.Clone(f.PaletteDir), palette.SearchPath...)
+	return nil
+}
+
+type ListCmd struct{}
+
+func (c *ListCmd) Run() error {
+	found, err := palette.SearchPathEntries()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, e := range palette.Registered() {
+		fmt.Fprintf(tw, "%s\t%3d colors\t%s\n", e.Name, len(e.Colors), e.Description)
+	}
+	for _, e := range found {
+		fmt.Fprintf(tw, "%s\t%3d colors\t%s\n", e.Name, len(e.Colors), e.File)
+	}
+	return tw.Flush()
+}
+
+type ShowCmd struct {
+	Name string `arg:"" help:"Palette name or file"`
+}
+
+func (c *ShowCmd) Run() error {
+	pals, err := palette.LoadPalettes(c.Name)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	for i, pal := range pals {
+		if len(pals) > 1 {
+			if i > 0 {
+				fmt.Fprintln(tw)
+			}
+			fmt.Fprintf(tw, "palette %d: %s\n", i, pal.Name)
+		}
+
+		fmt.Fprintln(tw, "#\tHEX\tR\tG\tB\tA\tL\ta\tb\tC\th\t")
+		for j, col := range pal.Colors {
+			c := color.NRGBAModel.Convert(col).(color.NRGBA)
+			lab := okcolor.LabModel.Convert(col).(okcolor.Lab)
+			lch := lab.LCh()
+			hue := "-"
+			if lch.C >= palette.AchromaticChroma {
+				hue = fmt.Sprintf("%.1f", math.Mod(lch.H*180/math.Pi+360, 360))
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%.4f\t%.4f\t%.4f\t%.4f\t%s\t\n",
+				j, palette.Hex(c), c.R, c.G, c.B, c.A, lab.L, lab.A, lab.B, lch.C, hue)
+		}
+	}
+	return tw.Flush()
+}
+
+type ConvertCmd struct {
+	In     string `arg:"" help:"Source palette name or file, optionally followed by #index or #name to select one of the palettes in it"`
+	Out    string `arg:"" help:"Destination palette file" type:"path"`
+	Format string `help:"Destination format (riff, gpl, jasc, paintnet, hex, act, aco, ase). If not given, it is picked by file extension"`
+}
+
+func (c *ConvertCmd) Run() error {
+	pals, err := palette.LoadPalettes(c.In)
+	if err != nil {
+		return err
+	}
+	return palette.SavePalettesToFile(c.Out, c.Format, pals)
+}
+
+type ExtractCmd struct {
+	Image     string `arg:"" help:"Image to extract the palette from" type:"existingfile"`
+	Out       string `arg:"" help:"Destination palette file" type:"path"`
+	MaxColors int    `help:"Maximum number of colors. If the image has more, they are reduced using the quantizer" default:"256"`
+	Quantizer string `help:"Method used to reduce the number of colors" enum:"median-cut,octree,kmeans" default:"kmeans"`
+}
+
+func (c *ExtractCmd) Run() error {
+	if c.MaxColors < 1 {
+		return fmt.Errorf("invalid maximum number of colors: %d", c.MaxColors)
+	}
+
+	img, err := loadImage(c.Image)
+	if err != nil {
+		return err
+	}
+
+	pal := palette.LoadPaletteFromImage(img)
+	if len(pal) > c.MaxColors {
+		lab, err := palette.Quantize(img, c.MaxColors, c.Quantizer)
+		if err != nil {
+			return err
+		}
+		_, pal = lab.To(color.RGBAModel)
+	}
+
+	palette.SortPalette(pal)
+	return palette.SavePaletteToFile(c.Out, pal)
+}
+
+type SortCmd struct {
+	In  string `arg:"" help:"Source palette name or file"`
+	Out string `arg:"" help:"Destination palette file" type:"path"`
+	By  string `help:"Sort order (rgb, hue, lightness, chroma, luminance, nearest)" enum:"rgb,hue,lightness,chroma,luminance,nearest" default:"rgb"`
+}
+
+func (c *SortCmd) Run() error {
+	pal, err := palette.LoadPalette(c.In)
+	if err != nil {
+		return err
+	}
+
+	pal = slices.Clone(pal)
+	if err = palette.SortPaletteBy(pal, c.By); err != nil {
+		return err
+	}
+	return palette.SavePaletteToFile(c.Out, pal)
+}
+
+type MergeCmd struct {
+	Out       string   `arg:"" help:"Destination palette file" type:"path"`
+	In        []string `arg:"" help:"Palette names or files to merge"`
+	Threshold float64  `help:"Drop colors closer than this to an already kept one (0 drops exact duplicates only)" default:"0"`
+	Metric    string   `help:"Color difference metric for the threshold (cie76, cie94, ciede2000, oklab, oklab-weighted[:W], redmean)" default:"oklab"`
+}
+
+func (c *MergeCmd) Run() error {
+	if c.Threshold < 0 {
+		return fmt.Errorf("invalid threshold: %g", c.Threshold)
+	}
+
+	metric, err := palette.ParseMetric(c.Metric)
+	if err != nil {
+		return err
+	}
+
+	pals := make([]color.Palette, len(c.In))
+	for i, name := range c.In {
+		if pals[i], err = palette.LoadPalette(name); err != nil {
+			return err
+		}
+	}
+
+	return palette.SavePaletteToFile(c.Out, palette.MergePalettes(metric, c.Threshold, pals...))
+}
+
+type ReduceCmd struct {
+	In     string `arg:"" help:"Source palette name or file"`
+	Out    string `arg:"" help:"Destination palette file" type:"path"`
+	Colors int    `help:"Number of colors to keep" required:""`
+	Method string `help:"Reduction method" enum:"farthest-point,k-medoids" default:"k-medoids"`
+}
+
+func (c *ReduceCmd) Run() error {
+	pal, err := palette.LoadPalette(c.In)
+	if err != nil {
+		return err
+	}
+
+	if pal, err = palette.Reduce(pal, c.Colors, c.Method); err != nil {
+		return err
+	}
+	return palette.SavePaletteToFile(c.Out, pal)
+}
+
+type CombineCmd struct {
+	Out    string   `arg:"" help:"Destination palette file" type:"path"`
+	In     []string `arg:"" help:"Palette names or files to combine"`
+	Format string   `help:"Destination format. If not given, it is picked by file extension"`
+}
+
+func (c *CombineCmd) Run() error {
+	var res []palette.Palette
+	for _, name := range c.In {
+		pals, err := palette.LoadPalettes(name)
+		if err != nil {
+			return err
+		}
+		res = append(res, pals...)
+	}
+
+	return palette.SavePalettesToFile(c.Out, c.Format, res)
+}
+
+type RenderCmd struct {
+	Name     string `arg:"" help:"Palette name or file"`
+	Out      string `arg:"" help:"Destination PNG image" type:"path"`
+	Columns  int    `help:"Chips per row" default:"16"`
+	ChipSize int    `help:"Chip size in pixels" default:"48"`
+	Padding  int    `help:"Space between chips in pixels" default:"4"`
+	Indexes  bool   `help:"Label chips with their index" default:"true" negatable:""`
+	Labels   bool   `help:"Label chips with their hex value" default:"true" negatable:""`
+	Sort     string `help:"Order of the chips (none, rgb, hue, lightness, chroma, luminance, nearest). Indexes always refer to the palette order" enum:"none,rgb,hue,lightness,chroma,luminance,nearest" default:"none"`
+}
+
+func (c *RenderCmd) Run() error {
+	pal, err := palette.LoadPalette(c.Name)
+	if err != nil {
+		return err
+	}
+
+	sw := palette.SwatchLayout{
+		Columns:  c.Columns,
+		ChipSize: c.ChipSize,
+		Padding:  c.Padding,
+		Indexes:  c.Indexes,
+		Labels:   c.Labels,
+	}
+	if c.Sort != "none" {
+		sw.Sort = c.Sort
+	}
+	img, err := sw.Render(pal)
+	if err != nil {
+		return err
+	}
+	return savePNG(c.Out, img)
+}
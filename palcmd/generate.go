@@ -0,0 +1,123 @@
+package palcmd
+
+import (
+	"fmt"
+	"image/color"
+
+	"picproc/palette"
+)
+
+type GenerateCmd struct {
+	Ramp     GenerateRampCmd     `cmd:"" help:"Generate a lightness ramp of a color"`
+	Wheel    GenerateWheelCmd    `cmd:"" help:"Generate evenly spaced hues at fixed lightness and chroma"`
+	Scheme   GenerateSchemeCmd   `cmd:"" help:"Generate a color scheme from a base color"`
+	Gradient GenerateGradientCmd `cmd:"" help:"Generate a gradient between two colors"`
+}
+
+// GenerateFlags are common to all generated palettes.
+type GenerateFlags struct {
+	Out    string `arg:"" help:"Destination palette file" type:"path"`
+	Clip   string `help:"Gamut clipping method for colors out of the sRGB gamut" enum:"preserve-chroma,project-to-05,project-to-cusp,adaptive-05,adaptive-cusp" default:"preserve-chroma"`
+	Format string `help:"Destination format. If not given, it is picked by file extension"`
+}
+
+func (f GenerateFlags) save(name string, pal color.Palette) error {
+	return palette.SavePalettesToFile(f.Out, f.Format, []palette.Palette{{Name: name, Colors: pal}})
+}
+
+func checkSteps(steps int) error {
+	if (steps < 1) || (steps > 256) {
+		return fmt.Errorf("number of steps must be between 1 and 256: %d", steps)
+	}
+	return nil
+}
+
+type GenerateRampCmd struct {
+	Base         string  `help:"Color whose hue and chroma are kept, in hex" required:""`
+	Steps        int     `help:"Number of colors" default:"8"`
+	MinLightness float64 `help:"Lightness of the first color (0..1)" default:"0.15"`
+	MaxLightness float64 `help:"Lightness of the last color (0..1)" default:"0.95"`
+	GenerateFlags
+}
+
+func (c *GenerateRampCmd) Run() error {
+	if err := checkSteps(c.Steps); err != nil {
+		return err
+	} else if (c.MinLightness < 0) || (c.MaxLightness > 1) || (c.MinLightness > c.MaxLightness) {
+		return fmt.Errorf("lightness must be between 0 and 1, minimum first: %g..%g", c.MinLightness, c.MaxLightness)
+	}
+
+	base, err := palette.ParseHex(c.Base)
+	if err != nil {
+		return err
+	}
+
+	pal := palette.GenerateRamp(base, c.Steps, c.MinLightness, c.MaxLightness, palette.Clippers[c.Clip])
+	return c.save("ramp "+palette.Hex(base), pal)
+}
+
+type GenerateWheelCmd struct {
+	Steps     int     `help:"Number of colors" default:"12"`
+	Lightness float64 `help:"OkLCh lightness (0..1)" default:"0.7"`
+	Chroma    float64 `help:"OkLCh chroma (0..0.37)" default:"0.12"`
+	Hue       float64 `help:"Hue of the first color, in degrees" default:"0"`
+	GenerateFlags
+}
+
+func (c *GenerateWheelCmd) Run() error {
+	if err := checkSteps(c.Steps); err != nil {
+		return err
+	} else if (c.Lightness < 0) || (c.Lightness > 1) {
+		return fmt.Errorf("lightness must be between 0 and 1: %g", c.Lightness)
+	} else if c.Chroma < 0 {
+		return fmt.Errorf("invalid chroma: %g", c.Chroma)
+	}
+
+	pal := palette.GenerateWheel(c.Steps, c.Lightness, c.Chroma, c.Hue, palette.Clippers[c.Clip])
+	return c.save("wheel", pal)
+}
+
+type GenerateSchemeCmd struct {
+	Base   string  `help:"Base color, in hex" required:""`
+	Scheme string  `help:"Color scheme" enum:"complementary,analogous,triadic,split-complementary,tetradic" default:"complementary"`
+	Angle  float64 `help:"Hue difference between analogous colors, in degrees" default:"30"`
+	GenerateFlags
+}
+
+func (c *GenerateSchemeCmd) Run() error {
+	base, err := palette.ParseHex(c.Base)
+	if err != nil {
+		return err
+	}
+
+	pal, err := palette.GenerateScheme(base, c.Scheme, c.Angle, palette.Clippers[c.Clip])
+	if err != nil {
+		return err
+	}
+	return c.save(c.Scheme+" "+palette.Hex(base), pal)
+}
+
+type GenerateGradientCmd struct {
+	From  string `help:"First color, in hex" required:""`
+	To    string `help:"Last color, in hex" required:""`
+	Steps int    `help:"Number of colors" default:"8"`
+	GenerateFlags
+}
+
+func (c *GenerateGradientCmd) Run() error {
+	if err := checkSteps(c.Steps); err != nil {
+		return err
+	}
+
+	from, err := palette.ParseHex(c.From)
+	if err != nil {
+		return err
+	}
+	to, err := palette.ParseHex(c.To)
+	if err != nil {
+		return err
+	}
+
+	pal := palette.GenerateGradient(from, to, c.Steps, palette.Clippers[c.Clip])
+	return c.save("gradient "+palette.Hex(from)+" "+palette.Hex(to), pal)
+}
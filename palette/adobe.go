@@ -0,0 +1,462 @@
+package palette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"unicode/utf16"
+
+	"picproc/okcolor"
+)
+
+// Swatch is a named palette color, as stored by Adobe swatch formats.
+type Swatch struct {
+	Name  string
+	Group string
+	Color color.Color
+}
+
+// swatchesToPalettes makes a palette out of each swatch group, in order of
+// appearance.
+func swatchesToPalettes(swatches []Swatch) []Palette {
+	var res []Palette
+	groups := make(map[string]int)
+	for _, s := range swatches {
+		i, ok := groups[s.Group]
+		if !ok {
+			i = len(res)
+			groups[s.Group] = i
+			res = append(res, Palette{Name: s.Group})
+		}
+		res[i].Colors = append(res[i].Colors, s.Color)
+		res[i].ColorNames = append(res[i].ColorNames, s.Name)
+	}
+	return res
+}
+
+// palettesToSwatches groups the colors of each palette by its name, unless
+// there is only one.
+func palettesToSwatches(pals []Palette) []Swatch {
+	var res []Swatch
+	for _, pal := range pals {
+		for i, col := range pal.Colors {
+			s := Swatch{Color: col}
+			if i < len(pal.ColorNames) {
+				s.Name = pal.ColorNames[i]
+			}
+			if s.Name == "" {
+				c := color.NRGBAModel.Convert(col).(color.NRGBA)
+				s.Name = fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+			}
+			if len(pals) > 1 {
+				s.Group = pal.Name
+			}
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+/*
+Adobe Color Table: 256 RGB triplets, optionally followed by
+  uint16 number of colors
+  uint16 transparent index, 0xFFFF if none
+all big endian.
+*/
+
+const (
+	actColors   = 256
+	actSize     = actColors * 3
+	actNoTransp = 0xFFFF
+)
+
+// ReadACT reads an Adobe Color Table. The transparent color, if any, is
+// returned with zero alpha.
+func ReadACT(r io.Reader) (color.Palette, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, actSize+4))
+	if err != nil {
+		return nil, fmt.Errorf("could not read ACT: %w", err)
+	} else if len(buf) < actSize {
+		return nil, fmt.Errorf("not enough bytes in ACT: %d", len(buf))
+	}
+
+	count, transparent := actColors, actNoTransp
+	if len(buf) == actSize+4 {
+		count = int(binary.BigEndian.Uint16(buf[actSize:]))
+		transparent = int(binary.BigEndian.Uint16(buf[actSize+2:]))
+		if count > actColors {
+			return nil, fmt.Errorf("invalid ACT number of colors: %d", count)
+		} else if count == 0 {
+			count = actColors
+		}
+		if (transparent != actNoTransp) && (transparent >= count) {
+			return nil, fmt.Errorf("ACT transparent index out of range: %d/%d", transparent, count)
+		}
+	}
+
+	res := make(color.Palette, count)
+	for i := range count {
+		c := color.NRGBA{R: buf[i*3], G: buf[i*3+1], B: buf[i*3+2], A: 0xFF}
+		if i == transparent {
+			c.A = 0
+		}
+		res[i] = c
+	}
+	return res, nil
+}
+
+func WriteACT(w io.Writer, pal color.Palette) (int64, error) {
+	if len(pal) > actColors {
+		return 0, fmt.Errorf("too many colors for ACT: %d", len(pal))
+	}
+
+	buf := make([]byte, actSize, actSize+4)
+	transparent := actNoTransp
+	for i, col := range pal {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		buf[i*3], buf[i*3+1], buf[i*3+2] = c.R, c.G, c.B
+		if (c.A == 0) && (transparent == actNoTransp) {
+			transparent = i
+		}
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(pal)))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(transparent))
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+/*
+Adobe Color Swatch, all big endian:
+  uint16 version (1)
+  uint16 number of colors
+  colors: uint16 color space, uint16 values[4]
+optionally followed by a version 2 section, with each color followed by
+  uint32 name length in UTF-16 code units, including the terminating zero
+  uint16 name[length]
+*/
+
+const (
+	acoRGB  = 0
+	acoHSB  = 1
+	acoCMYK = 2
+	acoLab  = 7
+	acoGray = 8
+)
+
+func ReadACO(r io.Reader) ([]Swatch, error) {
+	var res []Swatch
+	for {
+		var hdr [2]uint16
+		if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+			if errors.Is(err, io.EOF) && (res != nil) {
+				return res, nil
+			}
+			return res, fmt.Errorf("could not read ACO header: %w", err)
+		}
+
+		version, count := hdr[0], int(hdr[1])
+		if (version != 1) && (version != 2) {
+			return res, fmt.Errorf("unsupported ACO version: %d", version)
+		}
+
+		swatches := make([]Swatch, count)
+		for i := range count {
+			var entry [5]uint16
+			if err := binary.Read(r, binary.BigEndian, &entry); err != nil {
+				return res, fmt.Errorf("could not read ACO color %d/%d: %w", i, count, err)
+			}
+
+			col, err := acoColor(entry[0], entry[1:])
+			if err != nil {
+				return res, fmt.Errorf("invalid ACO color %d/%d: %w", i, count, err)
+			}
+			swatches[i].Color = col
+
+			if version == 2 {
+				if swatches[i].Name, err = readUTF16(r, 4); err != nil {
+					return res, fmt.Errorf("could not read ACO color name %d/%d: %w", i, count, err)
+				}
+			}
+		}
+
+		// version 2 repeats the colors of version 1, adding names
+		res = swatches
+		if version == 2 {
+			return res, nil
+		}
+	}
+}
+
+func acoColor(space uint16, v []uint16) (color.Color, error) {
+	switch space {
+	case acoRGB:
+		return color.RGBA64{R: v[0], G: v[1], B: v[2], A: 0xFFFF}, nil
+	case acoHSB:
+		return hsbToRGB(float64(v[0])/65535*360, float64(v[1])/65535, float64(v[2])/65535), nil
+	case acoCMYK:
+		// 0 is full ink
+		return color.CMYK{C: 0xFF - uint8(v[0]>>8), M: 0xFF - uint8(v[1]>>8), Y: 0xFF - uint8(v[2]>>8), K: 0xFF - uint8(v[3]>>8)}, nil
+	case acoLab:
+		return okcolor.CIELab{L: float64(v[0]) / 100, A: float64(int16(v[1])) / 100, B: float64(int16(v[2])) / 100, Alpha: 0xFFFF}.LinearRGBA(), nil
+	case acoGray:
+		return color.Gray16{Y: uint16(min(float64(v[0])/10000, 1) * 0xFFFF)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported color space: %d", space)
+	}
+}
+
+func hsbToRGB(h, s, v float64) color.Color {
+	f := func(n float64) uint16 {
+		k := math.Mod(n+h/60, 6)
+		return uint16((v - v*s*max(0, min(k, 4-k, 1))) * 0xFFFF)
+	}
+	return color.RGBA64{R: f(5), G: f(3), B: f(1), A: 0xFFFF}
+}
+
+// WriteACO writes both a version 1 and a version 2 section, as Photoshop does.
+func WriteACO(w io.Writer, swatches []Swatch) (int64, error) {
+	if len(swatches) > math.MaxUint16 {
+		return 0, fmt.Errorf("too many colors for ACO: %d", len(swatches))
+	}
+
+	var buf bytes.Buffer
+	for _, version := range []uint16{1, 2} {
+		binary.Write(&buf, binary.BigEndian, [2]uint16{version, uint16(len(swatches))})
+		for _, s := range swatches {
+			c := color.RGBA64Model.Convert(s.Color).(color.RGBA64)
+			binary.Write(&buf, binary.BigEndian, [5]uint16{acoRGB, c.R, c.G, c.B, 0})
+			if version == 2 {
+				writeUTF16(&buf, s.Name, 4)
+			}
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+/*
+Adobe Swatch Exchange, all big endian:
+  "ASEF", uint16 major version (1), uint16 minor version (0)
+  uint32 number of blocks
+  blocks: uint16 type, uint32 length, data
+    group start: uint16 name length, UTF-16 name
+    group end: no data
+    color: uint16 name length, UTF-16 name, 4 byte color model,
+           float32 values (RGB 3, LAB 3, CMYK 4, Gray 1), uint16 color type
+*/
+
+var aseMagic = []byte("ASEF")
+
+const (
+	aseGroupStart = 0xC001
+	aseGroupEnd   = 0xC002
+	aseColor      = 0x0001
+	aseNormal     = 2
+)
+
+func detectASE(header []byte) bool {
+	return bytes.HasPrefix(header, aseMagic)
+}
+
+func ReadASE(r io.Reader) ([]Swatch, error) {
+	var hdr struct {
+		Magic  [4]byte
+		Major  uint16
+		Minor  uint16
+		Blocks uint32
+	}
+	if err := binary.Read(r, binary.BigEndian, &hdr); err != nil {
+		return nil, fmt.Errorf("could not read ASE header: %w", err)
+	} else if !bytes.Equal(hdr.Magic[:], aseMagic) {
+		return nil, fmt.Errorf("invalid ASE magic: %q", hdr.Magic[:])
+	} else if hdr.Major != 1 {
+		return nil, fmt.Errorf("unsupported ASE version: %d.%d", hdr.Major, hdr.Minor)
+	}
+
+	var res []Swatch
+	var group string
+	for i := range hdr.Blocks {
+		var block struct {
+			Type   uint16
+			Length uint32
+		}
+		if err := binary.Read(r, binary.BigEndian, &block); err != nil {
+			return res, fmt.Errorf("could not read ASE block %d/%d: %w", i, hdr.Blocks, err)
+		}
+
+		// the length comes from the file, so only allocate what is really there
+		data, err := io.ReadAll(io.LimitReader(r, int64(block.Length)))
+		if err != nil {
+			return res, fmt.Errorf("could not read ASE block %d/%d: %w", i, hdr.Blocks, err)
+		} else if len(data) != int(block.Length) {
+			return res, fmt.Errorf("could not read ASE block %d/%d: %w", i, hdr.Blocks, io.ErrUnexpectedEOF)
+		}
+		br := bytes.NewReader(data)
+
+		switch block.Type {
+		case aseGroupStart:
+			name, err := readUTF16(br, 2)
+			if err != nil {
+				return res, fmt.Errorf("could not read ASE group name %d/%d: %w", i, hdr.Blocks, err)
+			}
+			group = name
+		case aseGroupEnd:
+			group = ""
+		case aseColor:
+			s, err := readASEColor(br)
+			if err != nil {
+				return res, fmt.Errorf("invalid ASE color %d/%d: %w", i, hdr.Blocks, err)
+			}
+			s.Group = group
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func readASEColor(r io.Reader) (Swatch, error) {
+	name, err := readUTF16(r, 2)
+	if err != nil {
+		return Swatch{}, fmt.Errorf("could not read name: %w", err)
+	}
+
+	var model [4]byte
+	if _, err = io.ReadFull(r, model[:]); err != nil {
+		return Swatch{}, fmt.Errorf("could not read color model: %w", err)
+	}
+
+	var count int
+	switch string(model[:]) {
+	case "RGB ", "LAB ":
+		count = 3
+	case "CMYK":
+		count = 4
+	case "Gray":
+		count = 1
+	default:
+		return Swatch{}, fmt.Errorf("unsupported color model: %q", model[:])
+	}
+
+	v := make([]float32, count)
+	if err = binary.Read(r, binary.BigEndian, v); err != nil {
+		return Swatch{}, fmt.Errorf("could not read %q values: %w", model[:], err)
+	}
+
+	unit := func(f float32) float64 {
+		return min(max(float64(f), 0), 1)
+	}
+
+	var col color.Color
+	switch string(model[:]) {
+	case "RGB ":
+		col = color.RGBA64{R: uint16(unit(v[0]) * 0xFFFF), G: uint16(unit(v[1]) * 0xFFFF), B: uint16(unit(v[2]) * 0xFFFF), A: 0xFFFF}
+	case "LAB ":
+		col = okcolor.CIELab{L: float64(v[0]) * 100, A: float64(v[1]), B: float64(v[2]), Alpha: 0xFFFF}.LinearRGBA()
+	case "CMYK":
+		col = color.CMYK{C: uint8(unit(v[0]) * 0xFF), M: uint8(unit(v[1]) * 0xFF), Y: uint8(unit(v[2]) * 0xFF), K: uint8(unit(v[3]) * 0xFF)}
+	case "Gray":
+		col = color.Gray16{Y: uint16(unit(v[0]) * 0xFFFF)}
+	}
+
+	return Swatch{Name: name, Color: col}, nil
+}
+
+// WriteASE writes RGB colors, grouping consecutive swatches that share a
+// group name.
+func WriteASE(w io.Writer, swatches []Swatch) (int64, error) {
+	var blocks bytes.Buffer
+	var count uint32
+	writeBlock := func(typ uint16, data []byte) {
+		binary.Write(&blocks, binary.BigEndian, typ)
+		binary.Write(&blocks, binary.BigEndian, uint32(len(data)))
+		blocks.Write(data)
+		count++
+	}
+
+	group := ""
+	for _, s := range swatches {
+		if s.Group != group {
+			if group != "" {
+				writeBlock(aseGroupEnd, nil)
+			}
+			if s.Group != "" {
+				var data bytes.Buffer
+				writeUTF16(&data, s.Group, 2)
+				writeBlock(aseGroupStart, data.Bytes())
+			}
+			group = s.Group
+		}
+
+		c := color.RGBA64Model.Convert(s.Color).(color.RGBA64)
+		var data bytes.Buffer
+		writeUTF16(&data, s.Name, 2)
+		data.WriteString("RGB ")
+		binary.Write(&data, binary.BigEndian, [3]float32{
+			float32(c.R) / 0xFFFF,
+			float32(c.G) / 0xFFFF,
+			float32(c.B) / 0xFFFF,
+		})
+		binary.Write(&data, binary.BigEndian, uint16(aseNormal))
+		writeBlock(aseColor, data.Bytes())
+	}
+	if group != "" {
+		writeBlock(aseGroupEnd, nil)
+	}
+
+	var buf bytes.Buffer
+	buf.Write(aseMagic)
+	binary.Write(&buf, binary.BigEndian, [2]uint16{1, 0})
+	binary.Write(&buf, binary.BigEndian, count)
+	blocks.WriteTo(&buf)
+
+	return buf.WriteTo(w)
+}
+
+// maxUTF16Length is the longest string readUTF16 accepts, in code units, so a
+// corrupt length cannot force a huge allocation.
+const maxUTF16Length = math.MaxUint16
+
+// readUTF16 reads a big endian UTF-16 string, prefixed by its length in code
+// units, including a terminating zero. The length takes lenSize bytes.
+func readUTF16(r io.Reader, lenSize int) (string, error) {
+	var length uint32
+	if lenSize == 2 {
+		var l16 uint16
+		if err := binary.Read(r, binary.BigEndian, &l16); err != nil {
+			return "", err
+		}
+		length = uint32(l16)
+	} else if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return "", err
+	}
+
+	if length > maxUTF16Length {
+		return "", fmt.Errorf("string too long: %d", length)
+	}
+
+	units := make([]uint16, length)
+	if err := binary.Read(r, binary.BigEndian, units); err != nil {
+		return "", err
+	}
+	for (len(units) > 0) && (units[len(units)-1] == 0) {
+		units = units[:len(units)-1]
+	}
+
+	return string(utf16.Decode(units)), nil
+}
+
+func writeUTF16(buf *bytes.Buffer, s string, lenSize int) {
+	units := append(utf16.Encode([]rune(s)), 0)
+	if lenSize == 2 {
+		binary.Write(buf, binary.BigEndian, uint16(len(units)))
+	} else {
+		binary.Write(buf, binary.BigEndian, uint32(len(units)))
+	}
+	binary.Write(buf, binary.BigEndian, units)
+}
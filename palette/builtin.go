@@ -8,6 +8,7 @@ import (
 	"io/fs"
 	"maps"
 	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 )
@@ -25,81 +26,149 @@ func LoadPaletteFromImage(i image.Image) color.Palette {
 }
 
 func SortPalette(p color.Palette) {
-	slices.SortStableFunc(p, func(c1, c2 color.Color) int {
-		r1, g1, b1, a1 := c1.RGBA()
-		r2, g2, b2, a2 := c2.RGBA()
+	slices.SortStableFunc(p, compareRGBA)
+}
+
+func LoadPalette(name string) (color.Palette, error) {
+	pals, err := LoadPalettes(name)
+	if err != nil {
+		return nil, err
+	}
+	return Concat(pals), nil
+}
+
+// LoadPalettes resolves name to a registered palette, to a palette file,
+// trying the known extensions, or to a palette file in one of the SearchPath
+// directories, in that order. Registered names come first so stray files in
+// the working directory cannot shadow them; such a file can still be given
+// as a path, as in ./bw. A file name may be followed by #index or #name to
+// select one of the palettes it holds.
+func LoadPalettes(name string) ([]Palette, error) {
+	if e, ok := lookupRegistered(name); ok {
+		return []Palette{{Name: e.Name, Colors: e.Colors}}, nil
+	}
 
-		switch {
-		case r1 < r2:
-			return -1
-		case r1 > r2:
-			return 1
-		case g1 < g2:
-			return -1
-		case g1 > g2:
-			return 1
-		case b1 < b2:
-			return -1
-		case b1 > b2:
-			return 1
-		case a1 < a2:
-			return -1
-		case a1 > a2:
-			return 1
-		default:
-			return 0
+	if pals, err := loadNamedFile(name); (err != nil) || (pals != nil) {
+		return pals, err
+	}
+
+	if !filepath.IsAbs(name) {
+		for _, dir := range SearchPath {
+			if pals, err := loadNamedFile(filepath.Join(dir, name)); (err != nil) || (pals != nil) {
+				return pals, err
+			}
 		}
-	})
+	}
+	return nil, fmt.Errorf("palette not found: %q", name)
 }
 
-func LoadPalette(name string) (color.Palette, error) {
-	if _, err := os.Stat(name); err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return nil, fmt.Errorf("could not check palette file info: %w", err)
+// loadNamedFile loads the palette file called name, with or without one of
+// the known extensions and optionally followed by #index or #name. It returns
+// nil palettes and no error if there is no such file.
+func loadNamedFile(name string) ([]Palette, error) {
+	fileName, err := findPaletteFile(name)
+	if err != nil {
+		return nil, err
+	} else if fileName != "" {
+		return LoadPalettesFromFile(fileName)
+	}
+
+	if i := strings.LastIndexByte(name, '#'); i > 0 {
+		if fileName, err = findPaletteFile(name[:i]); err != nil {
+			return nil, err
+		} else if fileName != "" {
+			pals, err := LoadPalettesFromFile(fileName)
+			if err != nil {
+				return nil, err
+			}
+
+			pal, err := SelectPalette(pals, name[i+1:])
+			if err != nil {
+				return nil, fmt.Errorf("could not select palette from file %q: %w", fileName, err)
+			}
+			return []Palette{pal}, nil
 		}
-	} else {
-		return LoadPaletteFromFile(name)
 	}
+	return nil, nil
+}
 
-	extName := fmt.Sprintf("%s.pal", name)
-	if _, err := os.Stat(extName); err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return nil, fmt.Errorf("could not check palette file info: %w", err)
+// findPaletteFile returns name, or name with one of the known palette
+// extensions, if such a file exists, or an empty string otherwise.
+func findPaletteFile(name string) (string, error) {
+	candidates := []string{name}
+	for _, f := range formats {
+		for _, ext := range f.extensions {
+			candidates = append(candidates, name+ext)
 		}
-	} else {
-		return LoadPaletteFromFile(extName)
 	}
 
-	switch strings.ToLower(name) {
-	case "bw":
-		return BW, nil
-	case "spectra6":
-		return Spectra6, nil
-	case "mattdm6":
-		return Mattdm6, nil
-	case "gray16":
-		return Gray16, nil
-	case "vga16":
-		return VGA16, nil
-	case "vga256":
-		return VGA256, nil
-	default:
-		return nil, fmt.Errorf("palette not found: %q", name)
+	for _, fileName := range candidates {
+		if _, err := os.Stat(fileName); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", fmt.Errorf("could not check palette file info: %w", err)
+			}
+		} else {
+			return fileName, nil
+		}
 	}
+	return "", nil
 }
 
 func LoadPaletteFromFile(name string) (color.Palette, error) {
+	pals, err := LoadPalettesFromFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Concat(pals), nil
+}
+
+func LoadPalettesFromFile(name string) ([]Palette, error) {
 	file, err := os.Open(name)
 	if err != nil {
 		return nil, fmt.Errorf("could not open palette file %q: %w", name, err)
 	}
+	defer file.Close()
 
-	pals, err := ReadFrom(file)
+	pals, err := readFormat(file, name)
 	if err != nil {
 		return nil, fmt.Errorf("could not load palette from file %q: %w", name, err)
 	}
 
-	return slices.Concat(pals...), nil
+	return pals, nil
+}
+
+func SavePaletteToFile(name string, pal color.Palette) error {
+	return SavePalettesToFile(name, "", []Palette{{Colors: pal}})
+}
+
+// SavePaletteToFileAs saves the palette in the named format, or picks one by
+// file extension if format is empty.
+func SavePaletteToFileAs(name, format string, pal color.Palette) error {
+	return SavePalettesToFile(name, format, []Palette{{Colors: pal}})
+}
+
+// SavePalettesToFile saves the palettes in the named format, or picks one by
+// file extension if format is empty. Formats that cannot hold several
+// palettes get the concatenation of all colors.
+func SavePalettesToFile(name, format string, pals []Palette) (err error) {
+	file, err := os.Create(name)
+	if err != nil {
+		return fmt.Errorf("could not create palette file %q: %w", name, err)
+	}
+	defer func() {
+		if defErr := file.Close(); defErr != nil {
+			err = fmt.Errorf("could not close palette file %q: %w", name, defErr)
+		}
+	}()
+
+	if err = writeFormat(file, pals, name, format); err != nil {
+		return fmt.Errorf("could not write palette to file %q: %w", name, err)
+	}
+
+	if err = file.Sync(); err != nil {
+		return fmt.Errorf("could not flush palette file %q: %w", name, err)
+	}
+	return nil
 }
 
 func LoadLabPalette(name string) (*Lab, error) {
@@ -455,14 +524,14 @@ var (
 		color.RGBA{0x2C, 0x3C, 0x41, 0xFF},
 		color.RGBA{0x2C, 0x34, 0x41, 0xFF},
 		color.RGBA{0x2C, 0x30, 0x41, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
-		// color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
 	}
 	VGA256Lab, _        = LoadLabPalette("vga256")
 	VGA256LinearRGBA, _ = LoadLinearRGBAPalette("vga256")
@@ -0,0 +1,142 @@
+package palette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"slices"
+
+	"picproc/okcolor"
+)
+
+// Chart lays out a calibration chart: a grid of square patches, one per
+// palette color in order, inside a frame of the darkest palette color. A
+// chart shown on a device and photographed or scanned can be measured to find
+// the colors the device really displays.
+type Chart struct {
+	// Columns is the number of patches per row; if 0, the grid is made as
+	// square as possible.
+	Columns   int
+	PatchSize int
+	Border    int
+}
+
+var DefaultChart = Chart{PatchSize: 128, Border: 32}
+
+func (c Chart) grid(n int) (int, int) {
+	cols := c.Columns
+	if cols <= 0 {
+		cols = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	cols = max(min(cols, n), 1)
+	return cols, (n + cols - 1) / cols
+}
+
+// Size returns the dimensions of a chart of n colors.
+func (c Chart) Size(n int) image.Point {
+	cols, rows := c.grid(n)
+	return image.Pt(cols*c.PatchSize+2*c.Border, rows*c.PatchSize+2*c.Border)
+}
+
+func (c Chart) patch(i, n int) image.Rectangle {
+	cols, _ := c.grid(n)
+	x := c.Border + (i%cols)*c.PatchSize
+	y := c.Border + (i/cols)*c.PatchSize
+	return image.Rect(x, y, x+c.PatchSize, y+c.PatchSize)
+}
+
+func (c Chart) validate(n int) error {
+	if n == 0 {
+		return fmt.Errorf("empty palette")
+	} else if c.PatchSize < 1 {
+		return fmt.Errorf("invalid patch size: %d", c.PatchSize)
+	} else if c.Border < 0 {
+		return fmt.Errorf("invalid border: %d", c.Border)
+	}
+	return nil
+}
+
+// Render draws the chart of a palette, using only colors of the palette so it
+// can be shown as is on the device. Grid cells past the last color get the
+// lightest palette color. The image is paletted, unless the palette has more
+// colors than a paletted image can index.
+func (c Chart) Render(pal color.Palette) (draw.Image, error) {
+	if err := c.validate(len(pal)); err != nil {
+		return nil, err
+	}
+
+	lightness := make([]float64, len(pal))
+	for i, col := range pal {
+		lightness[i] = okcolor.LabModel.Convert(col).(okcolor.Lab).L
+	}
+	darkest := slices.Index(lightness, slices.Min(lightness))
+	lightest := slices.Index(lightness, slices.Max(lightness))
+
+	var img draw.Image
+	if r := (image.Rectangle{Max: c.Size(len(pal))}); len(pal) <= 256 {
+		img = image.NewPaletted(r, pal)
+	} else {
+		img = image.NewRGBA(r)
+	}
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: pal[darkest]}, image.Point{}, draw.Src)
+
+	cols, rows := c.grid(len(pal))
+	for i := range cols * rows {
+		idx := lightest
+		if i < len(pal) {
+			idx = i
+		}
+		draw.Draw(img, c.patch(i, len(pal)), &image.Uniform{C: pal[idx]}, image.Point{}, draw.Src)
+	}
+
+	return img, nil
+}
+
+// Measure reads the colors of the n patches from a photographed or scanned
+// chart, cropped to the outer edge of its frame. Only the central half of
+// each patch is sampled, taking the median of each channel to reject glare,
+// dust and bleeding from neighbouring patches.
+func (c Chart) Measure(img image.Image, n int) (color.Palette, error) {
+	if err := c.validate(n); err != nil {
+		return nil, err
+	}
+
+	size := c.Size(n)
+	b := img.Bounds()
+	sx := float64(b.Dx()) / float64(size.X)
+	sy := float64(b.Dy()) / float64(size.Y)
+
+	res := make(color.Palette, n)
+	for i := range n {
+		p := c.patch(i, n)
+		inset := c.PatchSize / 4
+		x0 := b.Min.X + int(float64(p.Min.X+inset)*sx)
+		x1 := b.Min.X + int(math.Ceil(float64(p.Max.X-inset)*sx))
+		y0 := b.Min.Y + int(float64(p.Min.Y+inset)*sy)
+		y1 := b.Min.Y + int(math.Ceil(float64(p.Max.Y-inset)*sy))
+		if (x1 <= x0) || (y1 <= y0) {
+			return nil, fmt.Errorf("chart image too small to measure patch %d: %dx%d", i, b.Dx(), b.Dy())
+		}
+
+		var chans [3][]uint32
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				r, g, bl, _ := img.At(x, y).RGBA()
+				chans[0] = append(chans[0], r)
+				chans[1] = append(chans[1], g)
+				chans[2] = append(chans[2], bl)
+			}
+		}
+
+		var med [3]uint16
+		for ch, vals := range chans {
+			slices.Sort(vals)
+			med[ch] = uint16(vals[len(vals)/2])
+		}
+		res[i] = color.NRGBAModel.Convert(color.RGBA64{R: med[0], G: med[1], B: med[2], A: 0xFFFF})
+	}
+
+	return res, nil
+}
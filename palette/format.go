@@ -0,0 +1,212 @@
+package palette
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/image/riff"
+)
+
+// format describes a palette file format. Formats are detected by header
+// first, and by file extension if no header matches.
+type format struct {
+	name       string
+	extensions []string
+	detect     func(header []byte) bool
+	read       func(r io.Reader) ([]Palette, error)
+	// write saves the palettes; formats holding a single palette get all
+	// colors and the name of the first one
+	write func(w io.Writer, pals []Palette) error
+}
+
+// headerSize is the number of bytes available to format detection
+const headerSize = 32
+
+// single adapts readers and writers of formats that hold a single unnamed
+// palette.
+func single(read func(io.Reader) (color.Palette, error), write func(io.Writer, color.Palette) (int64, error)) (func(io.Reader) ([]Palette, error), func(io.Writer, []Palette) error) {
+	return func(r io.Reader) ([]Palette, error) {
+			pal, err := read(r)
+			if err != nil {
+				return nil, err
+			}
+			return []Palette{{Colors: pal}}, nil
+		}, func(w io.Writer, pals []Palette) error {
+			_, err := write(w, Concat(pals))
+			return err
+		}
+}
+
+var formats = []format{
+	{
+		name:       "riff",
+		extensions: []string{".pal"},
+		detect: func(header []byte) bool {
+			return (len(header) >= 12) && (riff.FourCC(header[0:4]) == riffType) && (riff.FourCC(header[8:12]) == palType)
+		},
+		read: ReadFrom,
+		write: func(w io.Writer, pals []Palette) error {
+			_, err := WriteTo(w, pals)
+			return err
+		},
+	},
+	{
+		name:       "gpl",
+		extensions: []string{".gpl"},
+		detect:     detectGPL,
+		read: func(r io.Reader) ([]Palette, error) {
+			pal, err := ReadGPL(r)
+			if err != nil {
+				return nil, err
+			}
+			return []Palette{{Name: pal.Name, Colors: pal.Colors, ColorNames: pal.Names, Columns: pal.Columns}}, nil
+		},
+		write: func(w io.Writer, pals []Palette) error {
+			_, err := WriteGPL(w, &GPL{
+				Name:    pals[0].Name,
+				Columns: pals[0].Columns,
+				Colors:  Concat(pals),
+				Names:   ConcatNames(pals),
+			})
+			return err
+		},
+	},
+	newFormat("jasc", []string{".jasc"}, detectJASC, ReadJASC, WriteJASC),
+	{
+		name:       "paintnet",
+		extensions: []string{".txt"},
+		detect:     detectPaintNET,
+		read: func(r io.Reader) ([]Palette, error) {
+			pal, err := ReadPaintNET(r)
+			if err != nil {
+				return nil, err
+			}
+			return []Palette{{Colors: pal}}, nil
+		},
+		write: func(w io.Writer, pals []Palette) error {
+			_, err := WritePaintNET(w, Concat(pals), pals[0].Name)
+			return err
+		},
+	},
+	newFormat("hex", []string{".hex"}, nil, ReadHex, WriteHex),
+	newFormat("act", []string{".act"}, nil, ReadACT, WriteACT),
+	{
+		name:       "aco",
+		extensions: []string{".aco"},
+		read: func(r io.Reader) ([]Palette, error) {
+			swatches, err := ReadACO(r)
+			if err != nil {
+				return nil, err
+			}
+			return swatchesToPalettes(swatches), nil
+		},
+		write: func(w io.Writer, pals []Palette) error {
+			_, err := WriteACO(w, palettesToSwatches(pals))
+			return err
+		},
+	},
+	{
+		name:       "ase",
+		extensions: []string{".ase"},
+		detect:     detectASE,
+		read: func(r io.Reader) ([]Palette, error) {
+			swatches, err := ReadASE(r)
+			if err != nil {
+				return nil, err
+			}
+			return swatchesToPalettes(swatches), nil
+		},
+		write: func(w io.Writer, pals []Palette) error {
+			_, err := WriteASE(w, palettesToSwatches(pals))
+			return err
+		},
+	},
+}
+
+func newFormat(name string, extensions []string, detect func([]byte) bool,
+	read func(io.Reader) (color.Palette, error), write func(io.Writer, color.Palette) (int64, error)) format {
+	f := format{
+		name:       name,
+		extensions: extensions,
+		detect:     detect,
+	}
+	f.read, f.write = single(read, write)
+	return f
+}
+
+func FormatNames() []string {
+	res := make([]string, len(formats))
+	for i, f := range formats {
+		res[i] = f.name
+	}
+	return res
+}
+
+func formatByName(name string) *format {
+	for i := range formats {
+		if formats[i].name == name {
+			return &formats[i]
+		}
+	}
+	return nil
+}
+
+// formatByExt returns the format matching the extension of fileName, or nil.
+func formatByExt(fileName string) *format {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	for i := range formats {
+		if slices.Contains(formats[i].extensions, ext) {
+			return &formats[i]
+		}
+	}
+	return nil
+}
+
+// readFormat detects the format of the stream, by its header or the
+// extension of fileName, and reads the palettes in it.
+func readFormat(r io.Reader, fileName string) ([]Palette, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(headerSize)
+	if (err != nil) && (err != io.EOF) && (err != bufio.ErrBufferFull) {
+		return nil, fmt.Errorf("could not read header: %w", err)
+	}
+
+	for _, f := range formats {
+		if (f.detect != nil) && f.detect(header) {
+			return f.read(br)
+		}
+	}
+
+	if f := formatByExt(fileName); f != nil {
+		return f.read(br)
+	}
+	return nil, fmt.Errorf("unknown palette format")
+}
+
+// writeFormat saves the palettes in the named format or, if formatName is
+// empty, in the one matching the extension of fileName, defaulting to RIFF.
+// A single unnamed palette is named after the file.
+func writeFormat(w io.Writer, pals []Palette, fileName, formatName string) error {
+	var f *format
+	if formatName != "" {
+		if f = formatByName(formatName); f == nil {
+			return fmt.Errorf("unsupported palette format: %q", formatName)
+		}
+	} else if f = formatByExt(fileName); f == nil {
+		f = &formats[0]
+	}
+
+	if len(pals) == 0 {
+		return fmt.Errorf("no palettes to write")
+	} else if (len(pals) == 1) && (pals[0].Name == "") {
+		pal := pals[0]
+		pal.Name = strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+		pals = []Palette{pal}
+	}
+	return f.write(w, pals)
+}
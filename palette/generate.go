@@ -0,0 +1,120 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+
+	"picproc/okcolor"
+)
+
+// Clippers maps colors generated out of the sRGB gamut back into it.
+var Clippers = map[string]okcolor.Clipper{
+	"preserve-chroma": okcolor.GamutClipPreserveChroma,
+	"project-to-05":   okcolor.GamutClipProjectTo05,
+	"project-to-cusp": okcolor.GamutClipProjectToLCusp,
+	"adaptive-05":     okcolor.GamutClipperAdaptive05(0.05),
+	"adaptive-cusp":   okcolor.GamutClipperAdaptiveLCusp(0.05),
+}
+
+func ClipperNames() []string {
+	return slices.Sorted(maps.Keys(Clippers))
+}
+
+// Schemes lists the hue offsets, in degrees, of the colors of each color
+// scheme relative to its base color. The analogous scheme is spread further
+// by GenerateScheme.
+var Schemes = map[string][]float64{
+	"complementary":       {0, 180},
+	"analogous":           {-1, 0, 1},
+	"triadic":             {0, 120, 240},
+	"split-complementary": {0, 150, 210},
+	"tetradic":            {0, 90, 180, 270},
+}
+
+func SchemeNames() []string {
+	return slices.Sorted(maps.Keys(Schemes))
+}
+
+// gamutMap converts an Oklab color to an opaque sRGB one, clipping it into
+// the gamut with clip.
+func gamutMap(lc okcolor.Lab, clip okcolor.Clipper) color.Color {
+	lc.Alpha = 0xFFFF
+	return color.NRGBAModel.Convert(lc.LinearRGBA(clip))
+}
+
+func radians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// GenerateRamp keeps the hue and chroma of base and steps lightness evenly
+// from minL to maxL, inclusive.
+func GenerateRamp(base color.Color, n int, minL, maxL float64, clip okcolor.Clipper) color.Palette {
+	lch := okcolor.LChModel.Convert(base).(okcolor.LCh)
+	res := make(color.Palette, n)
+	for i := range res {
+		lch.L = lerp(minL, maxL, i, n)
+		res[i] = gamutMap(lch.Lab(), clip)
+	}
+	return res
+}
+
+// GenerateWheel steps hue evenly around the color wheel, starting at hue
+// (in degrees), at fixed OkLCh lightness and chroma.
+func GenerateWheel(n int, lightness, chroma, hue float64, clip okcolor.Clipper) color.Palette {
+	res := make(color.Palette, n)
+	for i := range res {
+		lch := okcolor.LCh{L: lightness, C: chroma, H: radians(hue + 360*float64(i)/float64(n))}
+		res[i] = gamutMap(lch.Lab(), clip)
+	}
+	return res
+}
+
+// GenerateScheme rotates the hue of base by the offsets of the named scheme,
+// keeping its lightness and chroma. Colors of the analogous scheme are spread
+// angle degrees apart.
+func GenerateScheme(base color.Color, scheme string, angle float64, clip okcolor.Clipper) (color.Palette, error) {
+	offsets, ok := Schemes[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported color scheme: %q", scheme)
+	}
+
+	lch := okcolor.LChModel.Convert(base).(okcolor.LCh)
+	res := make(color.Palette, len(offsets))
+	for i, offset := range offsets {
+		if scheme == "analogous" {
+			offset *= angle
+		}
+		c := lch
+		c.H += radians(offset)
+		res[i] = gamutMap(c.Lab(), clip)
+	}
+	return res, nil
+}
+
+// GenerateGradient interpolates n colors from one color to another in Oklab,
+// inclusive of both.
+func GenerateGradient(from, to color.Color, n int, clip okcolor.Clipper) color.Palette {
+	lab1 := okcolor.LabModel.Convert(from).(okcolor.Lab)
+	lab2 := okcolor.LabModel.Convert(to).(okcolor.Lab)
+	res := make(color.Palette, n)
+	for i := range res {
+		res[i] = gamutMap(okcolor.Lab{
+			L: lerp(lab1.L, lab2.L, i, n),
+			A: lerp(lab1.A, lab2.A, i, n),
+			B: lerp(lab1.B, lab2.B, i, n),
+		}, clip)
+	}
+	return res
+}
+
+// lerp returns the i-th of n values evenly spread from a to b, inclusive.
+func lerp(a, b float64, i, n int) float64 {
+	if n < 2 {
+		return a
+	}
+	t := float64(i) / float64(n-1)
+	return a + (b-a)*t
+}
@@ -0,0 +1,116 @@
+package palette
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+GIMP Palette
+Name: Example
+Columns: 4
+# comment
+  0   0   0	Black
+255 255 255	White
+*/
+
+const gplMagic = "GIMP Palette"
+
+type GPL struct {
+	Name    string
+	Columns int
+	Colors  color.Palette
+	// Names holds the name of each color, empty if not given.
+	Names []string
+}
+
+func detectGPL(header []byte) bool {
+	return bytes.HasPrefix(bytes.TrimPrefix(header, []byte("\uFEFF")), []byte(gplMagic))
+}
+
+func ReadGPL(r io.Reader) (*GPL, error) {
+	scanner := bufio.NewScanner(r)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("could not read GPL header: %w", err)
+		}
+		return nil, fmt.Errorf("empty GPL stream")
+	}
+	if strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF")) != gplMagic {
+		return nil, fmt.Errorf("invalid GPL header: %q", scanner.Text())
+	}
+
+	res := &GPL{}
+	for lineNo := 2; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if (line == "") || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if name, ok := strings.CutPrefix(line, "Name:"); ok {
+			res.Name = strings.TrimSpace(name)
+			continue
+		}
+		if columns, ok := strings.CutPrefix(line, "Columns:"); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(columns))
+			if err != nil {
+				return res, fmt.Errorf("invalid GPL columns on line %d: %w", lineNo, err)
+			}
+			res.Columns = n
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return res, fmt.Errorf("invalid GPL color on line %d: %q", lineNo, line)
+		}
+
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.ParseUint(fields[i], 10, 8)
+			if err != nil {
+				return res, fmt.Errorf("invalid GPL color component on line %d: %w", lineNo, err)
+			}
+			rgb[i] = uint8(v)
+		}
+
+		res.Colors = append(res.Colors, color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xFF})
+		res.Names = append(res.Names, strings.Join(fields[3:], " "))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("could not read GPL stream: %w", err)
+	}
+	return res, nil
+}
+
+func WriteGPL(w io.Writer, pal *GPL) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, gplMagic)
+	if pal.Name != "" {
+		fmt.Fprintf(&buf, "Name: %s\n", pal.Name)
+	}
+	if pal.Columns > 0 {
+		fmt.Fprintf(&buf, "Columns: %d\n", pal.Columns)
+	}
+	fmt.Fprintln(&buf, "#")
+
+	for i, col := range pal.Colors {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		name := ""
+		if i < len(pal.Names) {
+			name = pal.Names[i]
+		}
+		if name == "" {
+			name = fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+		}
+		fmt.Fprintf(&buf, "%3d %3d %3d\t%s\n", c.R, c.G, c.B, name)
+	}
+
+	return buf.WriteTo(w)
+}
@@ -0,0 +1,268 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+
+	"picproc/okcolor"
+)
+
+// kdTree finds the nearest of a set of points, made of three color
+// coordinates and alpha, by squared Euclidean distance. Ties are broken in
+// favour of the lowest index, so results match a linear scan of the palette.
+type kdTree struct {
+	nodes []kdNode
+}
+
+type kdNode struct {
+	point       [4]float64
+	index       int
+	axis        int
+	left, right int
+}
+
+func newKDTree(points [][4]float64) *kdTree {
+	t := &kdTree{nodes: make([]kdNode, 0, len(points))}
+	order := make([]int, len(points))
+	for i := range order {
+		order[i] = i
+	}
+	t.build(points, order)
+	return t
+}
+
+// build adds the points in order to the tree and returns the index of their
+// root node, or -1 if there are none.
+func (t *kdTree) build(points [][4]float64, order []int) int {
+	if len(order) == 0 {
+		return -1
+	}
+
+	// split along the axis with the widest spread
+	axis, width := 0, -1.0
+	for a := range 4 {
+		lo, hi := points[order[0]][a], points[order[0]][a]
+		for _, i := range order[1:] {
+			lo, hi = min(lo, points[i][a]), max(hi, points[i][a])
+		}
+		if hi-lo > width {
+			axis, width = a, hi-lo
+		}
+	}
+
+	slices.SortFunc(order, func(a, b int) int {
+		if points[a][axis] < points[b][axis] {
+			return -1
+		} else if points[a][axis] > points[b][axis] {
+			return 1
+		}
+		return a - b
+	})
+
+	mid := len(order) / 2
+	n := len(t.nodes)
+	t.nodes = append(t.nodes, kdNode{point: points[order[mid]], index: order[mid], axis: axis})
+	left := t.build(points, order[:mid])
+	right := t.build(points, order[mid+1:])
+	t.nodes[n].left, t.nodes[n].right = left, right
+
+	return n
+}
+
+func (t *kdTree) nearest(p [4]float64) int {
+	if len(t.nodes) == 0 {
+		return 0
+	}
+
+	type pending struct {
+		node int
+		// bound is the squared distance from p to the splitting plane the
+		// node lies beyond
+		bound float64
+	}
+
+	// the tree is balanced, so its depth is at most log2 of the node count
+	var stackBuf [64]pending
+	stack := append(stackBuf[:0], pending{node: 0})
+	best, bestDist := -1, 0.0
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if (best >= 0) && (cur.bound > bestDist) {
+			continue
+		}
+
+		// descend to a leaf, queueing the far side of every split;
+		// equal distances must be explored for ties to resolve to the
+		// lowest index
+		for n := cur.node; n >= 0; {
+			node := &t.nodes[n]
+			d0 := p[0] - node.point[0]
+			d1 := p[1] - node.point[1]
+			d2 := p[2] - node.point[2]
+			d3 := p[3] - node.point[3]
+			dist := d0*d0 + d1*d1 + d2*d2 + d3*d3
+			if (best < 0) || (dist < bestDist) || ((dist == bestDist) && (node.index < best)) {
+				best, bestDist = node.index, dist
+			}
+
+			d := p[node.axis] - node.point[node.axis]
+			near, far := node.left, node.right
+			if d > 0 {
+				near, far = far, near
+			}
+			if far >= 0 {
+				stack = append(stack, pending{node: far, bound: d * d})
+			}
+			n = near
+		}
+	}
+
+	return best
+}
+
+// RGBAIndex is a palette with a k-d tree for fast nearest color lookups. It
+// gives the same results as color.Palette.Index.
+type RGBAIndex struct {
+	pal  color.Palette
+	tree *kdTree
+}
+
+func NewRGBAIndex(pal color.Palette) *RGBAIndex {
+	points := make([][4]float64, len(pal))
+	for i, col := range pal {
+		points[i] = rgbaPoint(col)
+	}
+	return &RGBAIndex{pal: pal, tree: newKDTree(points)}
+}
+
+func (p *RGBAIndex) Convert(c color.Color) color.Color {
+	if len(p.pal) == 0 {
+		return nil
+	}
+	return p.pal[p.Index(c)]
+}
+
+func (p *RGBAIndex) Index(c color.Color) int {
+	return p.tree.nearest(rgbaPoint(c))
+}
+
+func rgbaPoint(c color.Color) [4]float64 {
+	r, g, b, a := c.RGBA()
+	return [4]float64{float64(r) / 0xFFFF, float64(g) / 0xFFFF, float64(b) / 0xFFFF, float64(a) / 0xFFFF}
+}
+
+// LabIndex is a Lab palette with a k-d tree for fast nearest color lookups.
+// It gives the same results as Lab.Index.
+type LabIndex struct {
+	pal  Lab
+	tree *kdTree
+}
+
+func NewLabIndex(pal Lab) *LabIndex {
+	points := make([][4]float64, len(pal))
+	for i, lc := range pal {
+		points[i] = labPoint(lc)
+	}
+	return &LabIndex{pal: pal, tree: newKDTree(points)}
+}
+
+func (p *LabIndex) Convert(lc okcolor.Lab) okcolor.Lab {
+	if len(p.pal) == 0 {
+		return okcolor.Lab{}
+	}
+	return p.pal[p.Index(lc)]
+}
+
+func (p *LabIndex) Index(lc okcolor.Lab) int {
+	return p.tree.nearest(labPoint(lc))
+}
+
+func labPoint(lc okcolor.Lab) [4]float64 {
+	return [4]float64{lc.L, lc.A, lc.B, float64(lc.Alpha) / 0xFFFF}
+}
+
+// LinearRGBAIndex is a LinearRGBA palette with a k-d tree for fast nearest
+// color lookups. It gives the same results as LinearRGBA.Index.
+type LinearRGBAIndex struct {
+	pal  LinearRGBA
+	tree *kdTree
+}
+
+func NewLinearRGBAIndex(pal LinearRGBA) *LinearRGBAIndex {
+	points := make([][4]float64, len(pal))
+	for i, lc := range pal {
+		points[i] = linearPoint(lc)
+	}
+	return &LinearRGBAIndex{pal: pal, tree: newKDTree(points)}
+}
+
+func (p *LinearRGBAIndex) Convert(lc okcolor.LinearRGBA) okcolor.LinearRGBA {
+	if len(p.pal) == 0 {
+		return okcolor.LinearRGBA{}
+	}
+	return p.pal[p.Index(lc)]
+}
+
+func (p *LinearRGBAIndex) Index(lc okcolor.LinearRGBA) int {
+	return p.tree.nearest(linearPoint(lc))
+}
+
+func linearPoint(lc okcolor.LinearRGBA) [4]float64 {
+	return [4]float64{lc.R, lc.G, lc.B, float64(lc.A) / 0xFFFF}
+}
+
+// LUT caches nearest color lookups for opaque colors in a table of 2^(3*bits)
+// cells, keyed by the top bits of each sRGB channel. Each cell holds the match
+// for its center color, so results are approximate for colors near the
+// boundary between palette entries.
+// Translucent colors are passed through to the wrapped lookup.
+type LUT struct {
+	bits  uint
+	cells []uint16
+	index func(color.Color) int
+}
+
+// NewLUT fills a table using the given lookup, such as the Index method of a
+// palette.
+func NewLUT(bits int, index func(color.Color) int) (*LUT, error) {
+	if (bits < 1) || (bits > 8) {
+		return nil, fmt.Errorf("invalid LUT size: %d bits", bits)
+	}
+
+	l := &LUT{
+		bits:  uint(bits),
+		cells: make([]uint16, 1<<(3*bits)),
+		index: index,
+	}
+
+	size := 1 << bits
+	shift := 8 - l.bits
+	half := uint8((1 << shift) >> 1)
+	for r := range size {
+		for g := range size {
+			for b := range size {
+				c := color.RGBA{
+					R: uint8(r<<shift) | half,
+					G: uint8(g<<shift) | half,
+					B: uint8(b<<shift) | half,
+					A: 0xFF,
+				}
+				l.cells[(r<<(2*bits))|(g<<bits)|b] = uint16(index(c))
+			}
+		}
+	}
+
+	return l, nil
+}
+
+func (l *LUT) Index(c color.Color) int {
+	r, g, b, a := c.RGBA()
+	if a != 0xFFFF {
+		return l.index(c)
+	}
+
+	shift := 16 - l.bits
+	return int(l.cells[(r>>shift)<<(2*l.bits)|(g>>shift)<<l.bits|b>>shift])
+}
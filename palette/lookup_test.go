@@ -0,0 +1,135 @@
+package palette
+
+import (
+	"image/color"
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"picproc/okcolor"
+)
+
+func bruteNearest(pal Lab, lc okcolor.Lab) int {
+	best, bestDist := 0, math.MaxFloat64
+	for i, v := range pal {
+		p, q := labPoint(lc), labPoint(v)
+		var dist float64
+		for j := range p {
+			dist += (p[j] - q[j]) * (p[j] - q[j])
+		}
+		if dist < bestDist {
+			best, bestDist = i, dist
+		}
+	}
+	return best
+}
+
+func TestLabIndexMatchesLinearScan(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(3, 4))
+	randomLab := func() okcolor.Lab {
+		return okcolor.Lab{L: rnd.Float64(), A: rnd.Float64()*0.6 - 0.3, B: rnd.Float64()*0.6 - 0.3, Alpha: 0xFFFF}
+	}
+
+	for _, size := range []int{1, 2, 3, 16, 100, 256} {
+		for range 10 {
+			pal := make(Lab, size)
+			for i := range pal {
+				pal[i] = randomLab()
+			}
+			// duplicate entries and colors on a coarse grid give ties
+			if size > 2 {
+				pal[size-1] = pal[0]
+				for i := range pal[:size/2] {
+					pal[i].L = math.Round(pal[i].L*4) / 4
+					pal[i].A, pal[i].B = 0, 0
+				}
+			}
+
+			idx := NewLabIndex(pal)
+			for range 500 {
+				lc := randomLab()
+				if rnd.IntN(4) == 0 {
+					// exactly between grid entries, or on one
+					lc = okcolor.Lab{L: math.Round(lc.L*8) / 8, Alpha: 0xFFFF}
+				}
+				if got, want := idx.Index(lc), bruteNearest(pal, lc); got != want {
+					t.Fatalf("palette of %d: Index(%v) = %d, want %d", size, lc, got, want)
+				}
+				if got, want := idx.Index(lc), pal.Index(lc); got != want {
+					t.Fatalf("palette of %d: Index(%v) = %d, Lab.Index gives %d", size, lc, got, want)
+				}
+			}
+		}
+	}
+}
+
+// At 8 bits every cell center is an 8 bit color, so lookups of 8 bit colors
+// are exact, even next to the boundary between palette entries.
+func TestLUTMatchesExactLookup(t *testing.T) {
+	if testing.Short() {
+		t.Skip("filling an 8 bit table takes seconds")
+	}
+
+	idx := NewRGBAIndex(VGA256)
+	lut, err := NewLUT(8, idx.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewPCG(5, 6))
+	for range 100000 {
+		c := color.RGBA{R: uint8(rnd.UintN(256)), G: uint8(rnd.UintN(256)), B: uint8(rnd.UintN(256)), A: 0xFF}
+		if got, want := lut.Index(c), idx.Index(c); got != want {
+			t.Fatalf("LUT.Index(%v) = %d, want %d", c, got, want)
+		}
+	}
+
+	// translucent colors bypass the table
+	c := color.NRGBA{R: 10, G: 20, B: 30, A: 0x80}
+	if got, want := lut.Index(c), idx.Index(c); got != want {
+		t.Fatalf("LUT.Index(%v) = %d, want %d", c, got, want)
+	}
+}
+
+// benchColors returns random opaque colors to look up.
+func benchColors() []okcolor.Lab {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	res := make([]okcolor.Lab, 4096)
+	for i := range res {
+		c := color.RGBA{R: uint8(rnd.UintN(256)), G: uint8(rnd.UintN(256)), B: uint8(rnd.UintN(256)), A: 0xFF}
+		res[i] = okcolor.LabModel.Convert(c).(okcolor.Lab)
+	}
+	return res
+}
+
+func BenchmarkLabIndexLinear(b *testing.B) {
+	cols := benchColors()
+	b.ResetTimer()
+	for i := range b.N {
+		VGA256Lab.Index(cols[i%len(cols)])
+	}
+}
+
+func BenchmarkLabIndexKDTree(b *testing.B) {
+	cols := benchColors()
+	idx := NewLabIndex(*VGA256Lab)
+	b.ResetTimer()
+	for i := range b.N {
+		idx.Index(cols[i%len(cols)])
+	}
+}
+
+func BenchmarkLabIndexLUT(b *testing.B) {
+	cols := benchColors()
+	idx := NewLabIndex(*VGA256Lab)
+	lut, err := NewLUT(6, func(c color.Color) int {
+		return idx.Index(okcolor.LabModel.Convert(c).(okcolor.Lab))
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		lut.Index(cols[i%len(cols)])
+	}
+}
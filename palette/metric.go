@@ -0,0 +1,185 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"picproc/okcolor"
+)
+
+// Metric measures color differences for palette matching. Colors are first
+// mapped to coordinates, so palette entries are only converted once. The last
+// coordinate holds alpha, scaled to the units of the metric.
+type Metric struct {
+	Vector func(c color.Color) [4]float64
+	// Distance orders pairs of coordinates by their difference, x being the
+	// reference color. It returns the squared difference, to spare square
+	// roots in Euclidean metrics.
+	Distance func(x, y [4]float64) float64
+	// Euclidean is set if Distance is the squared Euclidean distance between
+	// coordinates, so lookups can use a k-d tree.
+	Euclidean bool
+}
+
+// DefaultLightnessWeight is used by the oklab-weighted metric if no weight is
+// given.
+const DefaultLightnessWeight = 2
+
+var Metrics = map[string]Metric{
+	"oklab":          OklabMetric,
+	"oklab-weighted": WeightedOklabMetric(DefaultLightnessWeight),
+	"cie76":          CIE76Metric,
+	"cie94":          CIE94Metric,
+	"ciede2000":      CIEDE2000Metric,
+	"redmean":        RedmeanMetric,
+}
+
+func MetricNames() []string {
+	return slices.Sorted(maps.Keys(Metrics))
+}
+
+// ParseMetric returns the named metric. The weighted Oklab metric takes an
+// optional lightness weight, as in "oklab-weighted:1.5".
+func ParseMetric(name string) (Metric, error) {
+	if w, ok := strings.CutPrefix(strings.ToLower(name), "oklab-weighted:"); ok {
+		wL, err := strconv.ParseFloat(w, 64)
+		if err != nil {
+			return Metric{}, fmt.Errorf("invalid lightness weight in metric %q: %w", name, err)
+		} else if wL <= 0 {
+			return Metric{}, fmt.Errorf("lightness weight in metric %q must be positive", name)
+		}
+		return WeightedOklabMetric(wL), nil
+	}
+
+	m, ok := Metrics[strings.ToLower(name)]
+	if !ok {
+		return Metric{}, fmt.Errorf("unsupported metric: %q", name)
+	}
+	return m, nil
+}
+
+// OklabMetric is the Euclidean distance in Oklab, as used by Lab.Index.
+var OklabMetric = WeightedOklabMetric(1)
+
+// WeightedOklabMetric scales lightness differences by wL relative to chroma
+// and hue differences. Weights above 1 favour preserving tones over hues.
+func WeightedOklabMetric(wL float64) Metric {
+	return Metric{
+		Vector: func(c color.Color) [4]float64 {
+			lc := okcolor.LabModel.Convert(c).(okcolor.Lab)
+			return [4]float64{lc.L * wL, lc.A, lc.B, float64(lc.Alpha) / 0xFFFF}
+		},
+		Distance:  squaredDistance,
+		Euclidean: true,
+	}
+}
+
+// cieVector returns CIELAB D50 coordinates, with alpha on the same scale as
+// lightness.
+func cieVector(c color.Color) [4]float64 {
+	lc := okcolor.LinearRGBAModel.Convert(c).(okcolor.LinearRGBA)
+	lab := lc.CIELab()
+	return [4]float64{lab.L, lab.A, lab.B, float64(lc.A) / 0xFFFF * 100}
+}
+
+// CIE76Metric is the Euclidean distance in CIELAB.
+var CIE76Metric = Metric{
+	Vector:    cieVector,
+	Distance:  squaredDistance,
+	Euclidean: true,
+}
+
+var CIE94Metric = Metric{
+	Vector: cieVector,
+	Distance: func(x, y [4]float64) float64 {
+		d := okcolor.DeltaE94([3]float64(x[:3]), [3]float64(y[:3]))
+		dA := x[3] - y[3]
+		return d*d + dA*dA
+	},
+}
+
+var CIEDE2000Metric = Metric{
+	Vector: cieVector,
+	Distance: func(x, y [4]float64) float64 {
+		d := okcolor.DeltaE2000([3]float64(x[:3]), [3]float64(y[:3]))
+		dA := x[3] - y[3]
+		return d*d + dA*dA
+	},
+}
+
+// RedmeanMetric weights sRGB channel differences by the mean red level. Alpha
+// differences weigh as much as green ones.
+var RedmeanMetric = Metric{
+	Vector: func(c color.Color) [4]float64 {
+		r, g, b, a := c.RGBA()
+		return [4]float64{float64(r) / 0x101, float64(g) / 0x101, float64(b) / 0x101, float64(a) / 0x101 * 2}
+	},
+	Distance: func(x, y [4]float64) float64 {
+		d := okcolor.RedmeanRGB([3]float64(x[:3]), [3]float64(y[:3]))
+		dA := x[3] - y[3]
+		return d*d + dA*dA
+	},
+}
+
+func squaredDistance(x, y [4]float64) float64 {
+	var sum float64
+	for i := range x {
+		d := x[i] - y[i]
+		sum += d * d
+	}
+	return sum
+}
+
+// MetricIndex finds the closest palette colors by a given metric.
+type MetricIndex struct {
+	pal     color.Palette
+	metric  Metric
+	entries [][4]float64
+	tree    *kdTree
+}
+
+func NewMetricIndex(pal color.Palette, metric Metric) *MetricIndex {
+	p := &MetricIndex{
+		pal:     pal,
+		metric:  metric,
+		entries: make([][4]float64, len(pal)),
+	}
+	for i, col := range pal {
+		p.entries[i] = metric.Vector(col)
+	}
+	if metric.Euclidean {
+		p.tree = newKDTree(p.entries)
+	}
+	return p
+}
+
+func (p *MetricIndex) Convert(c color.Color) color.Color {
+	if len(p.pal) == 0 {
+		return nil
+	}
+	return p.pal[p.Index(c)]
+}
+
+func (p *MetricIndex) Index(c color.Color) int {
+	v := p.metric.Vector(c)
+	if p.tree != nil {
+		return p.tree.nearest(v)
+	}
+
+	ret, bestSum := 0, math.MaxFloat64
+	for i, e := range p.entries {
+		sum := p.metric.Distance(v, e)
+		if sum < bestSum {
+			if sum == 0 {
+				return i
+			}
+			ret, bestSum = i, sum
+		}
+	}
+	return ret
+}
@@ -19,7 +19,7 @@ var (
 func NewLabPalette(p color.Palette) *Lab {
 	pal := &Lab{}
 	pal.From(p)
-	return nil
+	return pal
 }
 
 func (p *Lab) Convert(lc okcolor.Lab) okcolor.Lab {
@@ -35,8 +35,8 @@ func (p *Lab) Index(lc okcolor.Lab) int {
 		dL := lc.L - v.L
 		da := lc.A - v.A
 		db := lc.B - v.B
-		dA := lc.Alpha - v.Alpha
-		sum := dL*dL + da*da + db*db + float64(dA*dA)
+		dA := (float64(lc.Alpha) - float64(v.Alpha)) / 0xFFFF
+		sum := dL*dL + da*da + db*db + dA*dA
 		if sum < bestSum {
 			if sum == 0 {
 				return i
@@ -63,7 +63,7 @@ func (p *Lab) ReadRIFF(r io.Reader) (int64, error) {
 
 	var n int64
 	for _, pal := range pals {
-		n += p.From(pal)
+		n += p.From(pal.Colors)
 	}
 
 	return n, nil
@@ -77,17 +77,11 @@ func (p *Lab) To(m color.Model) (int64, color.Palette) {
 }
 
 func (p *Lab) ToPalette(m color.Model, pal *color.Palette) int64 {
-	n, pc, pn := len(*p), cap(*pal), len(*pal)
-	if pc < n+pn {
-		*pal = append(make(color.Palette, 0, n+pn), (*pal)...)
-	}
-
 	for _, lc := range *p {
-		(*pal)[n] = m.Convert(lc)
-		n++
+		*pal = append(*pal, m.Convert(lc))
 	}
 
-	return int64(pn)
+	return int64(len(*p))
 }
 
 func (p *Lab) WriteRIFF(w io.Writer) (int64, error) {
@@ -96,7 +90,7 @@ func (p *Lab) WriteRIFF(w io.Writer) (int64, error) {
 		pal[i] = color.RGBAModel.Convert(lc)
 	}
 
-	if n, err := WriteTo(w, []color.Palette{pal}); err != nil {
+	if n, err := WriteTo(w, []Palette{{Colors: pal}}); err != nil {
 		return n, fmt.Errorf("could not save palette: %w", err)
 	} else {
 		return n, nil
@@ -113,7 +107,7 @@ var (
 func NewLinearRGBAPalette(p color.Palette) *LinearRGBA {
 	pal := &LinearRGBA{}
 	pal.From(p)
-	return nil
+	return pal
 }
 
 func (p *LinearRGBA) Convert(lc okcolor.LinearRGBA) okcolor.LinearRGBA {
@@ -129,8 +123,8 @@ func (p *LinearRGBA) Index(lc okcolor.LinearRGBA) int {
 		dr := lc.R - v.R
 		dg := lc.G - v.G
 		db := lc.B - v.B
-		dA := lc.A - v.A
-		sum := dr*dr + dg*dg + db*db + float64(dA*dA)
+		dA := (float64(lc.A) - float64(v.A)) / 0xFFFF
+		sum := dr*dr + dg*dg + db*db + dA*dA
 		if sum < bestSum {
 			if sum == 0 {
 				return i
@@ -157,8 +151,8 @@ func (p *LinearRGBA) ReadRIFF(r io.Reader) (int64, error) {
 
 	n := 0
 	for _, pal := range pals {
-		n += len(pal)
-		for _, col := range pal {
+		n += len(pal.Colors)
+		for _, col := range pal.Colors {
 			*p = append(*p, okcolor.LinearRGBAModel.Convert(col).(okcolor.LinearRGBA))
 		}
 	}
@@ -174,17 +168,11 @@ func (p *LinearRGBA) To(m color.Model) (int64, color.Palette) {
 }
 
 func (p *LinearRGBA) ToPalette(m color.Model, pal *color.Palette) int64 {
-	n, pc, pn := len(*p), cap(*pal), len(*pal)
-	if pc < n+pn {
-		*pal = append(make(color.Palette, 0, n+pn), (*pal)...)
-	}
-
 	for _, lc := range *p {
-		(*pal)[n] = m.Convert(lc)
-		n++
+		*pal = append(*pal, m.Convert(lc))
 	}
 
-	return int64(pn)
+	return int64(len(*p))
 }
 
 func (p *LinearRGBA) WriteRIFF(w io.Writer) (int64, error) {
@@ -193,7 +181,7 @@ func (p *LinearRGBA) WriteRIFF(w io.Writer) (int64, error) {
 		pal[i] = color.RGBAModel.Convert(lc)
 	}
 
-	if n, err := WriteTo(w, []color.Palette{pal}); err != nil {
+	if n, err := WriteTo(w, []Palette{{Colors: pal}}); err != nil {
 		return n, fmt.Errorf("could not save palette: %w", err)
 	} else {
 		return n, nil
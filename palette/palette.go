@@ -0,0 +1,88 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Palette is a named list of colors, as stored in palette files.
+type Palette struct {
+	Name   string
+	Colors color.Palette
+	// ColorNames holds the name of each color for the formats that keep them,
+	// empty if not given. It is nil or as long as Colors.
+	ColorNames []string
+	// Columns is the number of columns to lay the colors out in, 0 if not
+	// given.
+	Columns int
+}
+
+// Concat joins the colors of all palettes.
+func Concat(pals []Palette) color.Palette {
+	res := make(color.Palette, 0)
+	for _, pal := range pals {
+		res = append(res, pal.Colors...)
+	}
+	return res
+}
+
+// ConcatNames joins the color names of all palettes, matching the colors of
+// Concat, or returns nil if no color has a name.
+func ConcatNames(pals []Palette) []string {
+	if !slices.ContainsFunc(pals, func(p Palette) bool { return len(p.ColorNames) > 0 }) {
+		return nil
+	}
+
+	res := make([]string, 0)
+	for _, pal := range pals {
+		names := make([]string, len(pal.Colors))
+		copy(names, pal.ColorNames)
+		res = append(res, names...)
+	}
+	return res
+}
+
+// TransparentIndex returns the index of the first fully transparent color in
+// the palette, or -1 if there is none.
+func TransparentIndex(pal color.Palette) int {
+	return slices.IndexFunc(pal, func(c color.Color) bool {
+		_, _, _, a := c.RGBA()
+		return a == 0
+	})
+}
+
+// SetTransparent returns a copy of the palette with the i-th color made fully
+// transparent.
+func SetTransparent(pal color.Palette, i int) (color.Palette, error) {
+	if (i < 0) || (i >= len(pal)) {
+		return nil, fmt.Errorf("transparent index out of range: %d/%d", i, len(pal))
+	}
+
+	res := slices.Clone(pal)
+	c := color.NRGBAModel.Convert(res[i]).(color.NRGBA)
+	c.A = 0
+	res[i] = c
+	return res, nil
+}
+
+// SelectPalette picks a palette by its 0-based index or, if sel is not a
+// number, by its case insensitive name.
+func SelectPalette(pals []Palette, sel string) (Palette, error) {
+	if i, err := strconv.Atoi(sel); err == nil {
+		if (i < 0) || (i >= len(pals)) {
+			return Palette{}, fmt.Errorf("palette index out of range: %d/%d", i, len(pals))
+		}
+		return pals[i], nil
+	}
+
+	i := slices.IndexFunc(pals, func(p Palette) bool {
+		return strings.EqualFold(p.Name, sel)
+	})
+	if i < 0 {
+		return Palette{}, fmt.Errorf("palette not found: %q", sel)
+	}
+	return pals[i], nil
+}
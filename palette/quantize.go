@@ -0,0 +1,369 @@
+package palette
+
+import (
+	"cmp"
+	"container/heap"
+	"fmt"
+	"image"
+	"maps"
+	"math"
+	"slices"
+
+	"picproc/okcolor"
+)
+
+// Quantizer picks at most n colors that best represent the given samples.
+type Quantizer func(samples []okcolor.Lab, n int) Lab
+
+var Quantizers = map[string]Quantizer{
+	"median-cut": MedianCut,
+	"octree":     Octree,
+	"kmeans":     KMeans,
+}
+
+func QuantizerNames() []string {
+	return slices.Sorted(maps.Keys(Quantizers))
+}
+
+// Quantize builds an n color palette for the image using the named quantizer.
+func Quantize(img image.Image, n int, method string) (Lab, error) {
+	return QuantizeSamples(SampleImage(img, maxSamples), n, method)
+}
+
+// QuantizeSamples builds an n color palette for the samples using the named
+// quantizer.
+func QuantizeSamples(samples []okcolor.Lab, n int, method string) (Lab, error) {
+	q, ok := Quantizers[method]
+	if !ok {
+		return nil, fmt.Errorf("unsupported quantizer: %q", method)
+	}
+	return q(samples, n), nil
+}
+
+const maxSamples = 1 << 16
+
+// SampleImage converts up to about max evenly spread pixels of the image to
+// Oklab, skipping fully transparent ones.
+func SampleImage(img image.Image, max int) []okcolor.Lab {
+	b := img.Bounds()
+	step := 1
+	if max > 0 {
+		step = int(math.Ceil(math.Sqrt(float64(b.Dx()*b.Dy()) / float64(max))))
+		step = cmp.Or(step, 1)
+	}
+
+	res := make([]okcolor.Lab, 0, (b.Dx()/step+1)*(b.Dy()/step+1))
+	for y := b.Min.Y; y < b.Max.Y; y += step {
+		for x := b.Min.X; x < b.Max.X; x += step {
+			c := img.At(x, y)
+			if _, _, _, a := c.RGBA(); a == 0 {
+				continue
+			}
+			res = append(res, okcolor.LabModel.Convert(c).(okcolor.Lab))
+		}
+	}
+	return res
+}
+
+type labBox []okcolor.Lab
+
+func (b labBox) widest() (int, float64) {
+	lo := [3]float64{math.MaxFloat64, math.MaxFloat64, math.MaxFloat64}
+	hi := [3]float64{-math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64}
+	for _, lc := range b {
+		for i, v := range labVector(lc) {
+			lo[i] = min(lo[i], v)
+			hi[i] = max(hi[i], v)
+		}
+	}
+
+	axis := 0
+	for i := range hi {
+		if hi[i]-lo[i] > hi[axis]-lo[axis] {
+			axis = i
+		}
+	}
+	return axis, hi[axis] - lo[axis]
+}
+
+func (b labBox) mean() okcolor.Lab {
+	var sum [3]float64
+	var alpha float64
+	for _, lc := range b {
+		sum[0] += lc.L
+		sum[1] += lc.A
+		sum[2] += lc.B
+		alpha += float64(lc.Alpha)
+	}
+	n := float64(len(b))
+	return okcolor.Lab{L: sum[0] / n, A: sum[1] / n, B: sum[2] / n, Alpha: uint16(alpha/n + 0.5)}
+}
+
+// MedianCut repeatedly splits the box of samples with the largest weighted
+// extent at the median of its widest axis.
+func MedianCut(samples []okcolor.Lab, n int) Lab {
+	if (len(samples) == 0) || (n < 1) {
+		return Lab{}
+	}
+
+	boxes := []labBox{slices.Clone(samples)}
+	for len(boxes) < n {
+		best, bestScore, bestAxis := -1, 0.0, 0
+		for i, b := range boxes {
+			if len(b) < 2 {
+				continue
+			}
+			axis, extent := b.widest()
+			if score := extent * float64(len(b)); score > bestScore {
+				best, bestScore, bestAxis = i, score, axis
+			}
+		}
+		if best < 0 {
+			break
+		}
+
+		b := boxes[best]
+		slices.SortFunc(b, func(c1, c2 okcolor.Lab) int {
+			return cmp.Compare(labVector(c1)[bestAxis], labVector(c2)[bestAxis])
+		})
+		mid := len(b) / 2
+		boxes[best] = b[:mid]
+		boxes = append(boxes, b[mid:])
+	}
+
+	res := make(Lab, len(boxes))
+	for i, b := range boxes {
+		res[i] = b.mean()
+	}
+	return res
+}
+
+const kMeansIterations = 16
+
+// KMeans refines a median cut palette with Lloyd's algorithm.
+func KMeans(samples []okcolor.Lab, n int) Lab {
+	centers := MedianCut(samples, n)
+	if len(centers) == 0 {
+		return centers
+	}
+
+	assign := make([]int, len(samples))
+	for i := range assign {
+		assign[i] = -1
+	}
+
+	sums := make([][4]float64, len(centers))
+	for range kMeansIterations {
+		changed := false
+		idx := NewLabIndex(centers)
+		for i, lc := range samples {
+			if idx := idx.Index(lc); idx != assign[i] {
+				assign[i], changed = idx, true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		clear(sums)
+		for i, lc := range samples {
+			s := &sums[assign[i]]
+			s[0] += lc.L
+			s[1] += lc.A
+			s[2] += lc.B
+			s[3]++
+		}
+		for i, s := range sums {
+			if s[3] == 0 {
+				continue
+			}
+			centers[i].L = s[0] / s[3]
+			centers[i].A = s[1] / s[3]
+			centers[i].B = s[2] / s[3]
+		}
+	}
+
+	return centers
+}
+
+const octreeDepth = 6
+
+type octreeNode struct {
+	children [8]*octreeNode
+	parent   *octreeNode
+	sum      [3]float64
+	alpha    float64
+	count    int
+	leaf     bool
+	// internal is the number of children that are not leaves yet
+	internal int
+}
+
+func (o *octreeNode) leaves(yield func(*octreeNode) bool) bool {
+	if o.leaf {
+		return yield(o)
+	}
+	for _, child := range o.children {
+		if (child != nil) && !child.leaves(yield) {
+			return false
+		}
+	}
+	return true
+}
+
+// merge adds the samples of child to o.
+func (o *octreeNode) merge(child *octreeNode) {
+	for j := range o.sum {
+		o.sum[j] += child.sum[j]
+	}
+	o.alpha += child.alpha
+}
+
+// octreeHeap holds the nodes whose children are all leaves, least populated
+// first.
+type octreeHeap []*octreeNode
+
+func (h octreeHeap) Len() int           { return len(h) }
+func (h octreeHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h octreeHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *octreeHeap) Push(x any)        { *h = append(*h, x.(*octreeNode)) }
+
+func (h *octreeHeap) Pop() any {
+	old := *h
+	o := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return o
+}
+
+// Octree bins samples in an octree over the bounding cube of the samples in
+// Oklab, then repeatedly merges the children of the least populated node
+// whose children are all leaves, until n leaves remain.
+func Octree(samples []okcolor.Lab, n int) Lab {
+	if (len(samples) == 0) || (n < 1) {
+		return Lab{}
+	}
+
+	// a cube rather than a box, so distances stay isotropic
+	lo := [3]float64{math.MaxFloat64, math.MaxFloat64, math.MaxFloat64}
+	hi := [3]float64{-math.MaxFloat64, -math.MaxFloat64, -math.MaxFloat64}
+	for _, lc := range samples {
+		for i, v := range labVector(lc) {
+			lo[i] = min(lo[i], v)
+			hi[i] = max(hi[i], v)
+		}
+	}
+	extent := max(hi[0]-lo[0], hi[1]-lo[1], hi[2]-lo[2])
+	if extent <= 0 {
+		extent = 1
+	}
+
+	root := &octreeNode{}
+	leafCount := 0
+	for _, lc := range samples {
+		var coord [3]int
+		for i, v := range labVector(lc) {
+			coord[i] = min(max(int((v-lo[i])/extent*(1<<octreeDepth)), 0), 1<<octreeDepth-1)
+		}
+
+		node := root
+		for depth := range octreeDepth {
+			shift := octreeDepth - 1 - depth
+			idx := (coord[0]>>shift&1)<<2 | (coord[1]>>shift&1)<<1 | (coord[2] >> shift & 1)
+			if node.children[idx] == nil {
+				child := &octreeNode{parent: node, leaf: depth == octreeDepth-1}
+				node.children[idx] = child
+				if child.leaf {
+					leafCount++
+				} else {
+					node.internal++
+				}
+			}
+			node = node.children[idx]
+		}
+
+		node.sum[0] += lc.L
+		node.sum[1] += lc.A
+		node.sum[2] += lc.B
+		node.alpha += float64(lc.Alpha)
+		for o := node; o != nil; o = o.parent {
+			o.count++
+		}
+	}
+
+	var reducible octreeHeap
+	var collect func(*octreeNode)
+	collect = func(o *octreeNode) {
+		if o.leaf {
+			return
+		}
+		if o.internal == 0 {
+			reducible = append(reducible, o)
+		}
+		for _, child := range o.children {
+			if child != nil {
+				collect(child)
+			}
+		}
+	}
+	collect(root)
+	heap.Init(&reducible)
+
+	for (leafCount > n) && (reducible.Len() > 0) {
+		node := heap.Pop(&reducible).(*octreeNode)
+
+		var children []*octreeNode
+		for _, child := range node.children {
+			if child != nil {
+				children = append(children, child)
+			}
+		}
+
+		if leafCount-(len(children)-1) < n {
+			// merging all children would overshoot, so only fold the least
+			// populated ones into one of them
+			slices.SortFunc(children, func(o1, o2 *octreeNode) int {
+				return cmp.Compare(o1.count, o2.count)
+			})
+			dst := children[0]
+			for _, child := range children[1 : leafCount-n+1] {
+				dst.merge(child)
+				dst.count += child.count
+				node.children[slices.Index(node.children[:], child)] = nil
+			}
+			leafCount = n
+			break
+		}
+
+		for i, child := range node.children {
+			if child != nil {
+				node.merge(child)
+				node.children[i] = nil
+			}
+		}
+		node.leaf = true
+		leafCount -= len(children) - 1
+
+		if p := node.parent; p != nil {
+			if p.internal--; p.internal == 0 {
+				heap.Push(&reducible, p)
+			}
+		}
+	}
+
+	res := make(Lab, 0, leafCount)
+	root.leaves(func(o *octreeNode) bool {
+		c := float64(o.count)
+		res = append(res, okcolor.Lab{
+			L:     o.sum[0] / c,
+			A:     o.sum[1] / c,
+			B:     o.sum[2] / c,
+			Alpha: uint16(o.alpha/c + 0.5),
+		})
+		return true
+	})
+	return res
+}
+
+func labVector(lc okcolor.Lab) [3]float64 {
+	return [3]float64{lc.L, lc.A, lc.B}
+}
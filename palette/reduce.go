@@ -0,0 +1,180 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+)
+
+// Reducer picks the indexes of n colors of a palette that best cover it, given
+// the coordinates of each color in Oklab.
+type Reducer func(points [][4]float64, n int) []int
+
+var Reducers = map[string]Reducer{
+	"farthest-point": FarthestPoint,
+	"k-medoids":      KMedoids,
+}
+
+func ReducerNames() []string {
+	return slices.Sorted(maps.Keys(Reducers))
+}
+
+// Reduce keeps at most n colors of the palette, chosen in Oklab by the named
+// method. Kept colors stay in palette order.
+func Reduce(p color.Palette, n int, method string) (color.Palette, error) {
+	r, ok := Reducers[method]
+	if !ok {
+		return nil, fmt.Errorf("unsupported reduction method: %q", method)
+	} else if n < 1 {
+		return nil, fmt.Errorf("invalid number of colors: %d", n)
+	}
+
+	if len(p) <= n {
+		return slices.Clone(p), nil
+	}
+
+	points := make([][4]float64, len(p))
+	for i, col := range p {
+		points[i] = OklabMetric.Vector(col)
+	}
+
+	keep := r(points, n)
+	slices.Sort(keep)
+	res := make(color.Palette, len(keep))
+	for i, idx := range keep {
+		res[i] = p[idx]
+	}
+	return res, nil
+}
+
+// FarthestPoint starts from the color farthest from the mean and repeatedly
+// adds the color farthest from all picked ones, so the extremes of the
+// palette are kept and the rest is covered evenly.
+func FarthestPoint(points [][4]float64, n int) []int {
+	n = min(n, len(points))
+	if n == 0 {
+		return nil
+	}
+
+	var mean [4]float64
+	for _, pt := range points {
+		for i := range pt {
+			mean[i] += pt[i] / float64(len(points))
+		}
+	}
+
+	first, far := 0, -1.0
+	for i, pt := range points {
+		if d := squaredDistance(pt, mean); d > far {
+			first, far = i, d
+		}
+	}
+
+	// nearest holds the distance of each color to the closest picked one
+	nearest := make([]float64, len(points))
+	for i := range nearest {
+		nearest[i] = math.MaxFloat64
+	}
+
+	res := make([]int, 0, n)
+	picked := make([]bool, len(points))
+	for next := first; len(res) < n; {
+		res = append(res, next)
+		picked[next] = true
+
+		// duplicates of picked colors are at distance 0, so unpicked ones
+		// are looked for explicitly
+		cur, far := next, -1.0
+		for i, pt := range points {
+			nearest[i] = min(nearest[i], squaredDistance(pt, points[cur]))
+			if !picked[i] && (nearest[i] > far) {
+				next, far = i, nearest[i]
+			}
+		}
+	}
+
+	return res
+}
+
+// maxMedoidIterations bounds the refinement of k-medoids.
+const maxMedoidIterations = 32
+
+// KMedoids starts from FarthestPoint and alternates between assigning every
+// color to its closest medoid and moving each medoid to the member of its
+// cluster with the least total distance to the others. Unlike FarthestPoint,
+// dense areas of the palette get more colors than sparse outliers.
+func KMedoids(points [][4]float64, n int) []int {
+	medoids := FarthestPoint(points, n)
+	if len(medoids) == 0 {
+		return medoids
+	}
+
+	dist := func(i, j int) float64 {
+		return math.Sqrt(squaredDistance(points[i], points[j]))
+	}
+
+	clusters := make([][]int, len(medoids))
+	for range maxMedoidIterations {
+		for k := range clusters {
+			clusters[k] = clusters[k][:0]
+		}
+		for i := range points {
+			best, bestDist := 0, math.MaxFloat64
+			for k, m := range medoids {
+				if d := dist(i, m); d < bestDist {
+					best, bestDist = k, d
+				}
+			}
+			clusters[best] = append(clusters[best], i)
+		}
+
+		changed := false
+		for k, members := range clusters {
+			best, bestCost := medoids[k], math.MaxFloat64
+			for _, candidate := range members {
+				var cost float64
+				for _, other := range members {
+					cost += dist(candidate, other)
+				}
+				if cost < bestCost {
+					best, bestCost = candidate, cost
+				}
+			}
+			if best != medoids[k] {
+				medoids[k] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	return medoids
+}
+
+// MergePalettes joins palettes, dropping every color that is within threshold
+// of an already kept one by the given metric. A zero threshold only drops
+// exact duplicates.
+func MergePalettes(metric Metric, threshold float64, pals ...color.Palette) color.Palette {
+	var (
+		res  color.Palette
+		kept [][4]float64
+	)
+	limit := threshold * threshold
+	for _, pal := range pals {
+		for _, col := range pal {
+			v := metric.Vector(col)
+			if slices.ContainsFunc(kept, func(k [4]float64) bool {
+				return metric.Distance(k, v) <= limit
+			}) {
+				continue
+			}
+			kept = append(kept, v)
+			res = append(res, col)
+		}
+	}
+	return res
+}
@@ -0,0 +1,138 @@
+package palette
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Entry describes a palette that can be loaded by name.
+type Entry struct {
+	Name        string
+	Description string
+	Colors      color.Palette
+	// File is the file the palette was found in, empty for registered ones.
+	File string
+}
+
+// registry holds the palettes added with Register, built-ins first. The
+// built-ins are listed here rather than registered from init, as the Lab and
+// linear variants of the built-in palettes are loaded by name during package
+// variable initialization.
+var registry = []Entry{
+	{Name: "bw", Description: "Black and white", Colors: BW},
+	{Name: "spectra6", Description: "Spectra 6 e-paper nominal colors", Colors: Spectra6},
+	{Name: "mattdm6", Description: "Spectra 6 e-paper colors as displayed", Colors: Mattdm6},
+	{Name: "gray16", Description: "16 evenly spaced grays", Colors: Gray16},
+	{Name: "vga16", Description: "Windows 16 color VGA", Colors: VGA16},
+	{Name: "vga256", Description: "VGA mode 13h default palette", Colors: VGA256},
+	{Name: "cga16", Description: "CGA text mode colors", Colors: CGA16},
+	{Name: "cga0", Description: "CGA graphics palette 0, low intensity", Colors: CGA0},
+	{Name: "cga0-high", Description: "CGA graphics palette 0, high intensity", Colors: CGA0High},
+	{Name: "cga1", Description: "CGA graphics palette 1, low intensity", Colors: CGA1},
+	{Name: "cga1-high", Description: "CGA graphics palette 1, high intensity", Colors: CGA1High},
+	{Name: "cga5", Description: "CGA mode 5 palette, low intensity", Colors: CGA5},
+	{Name: "cga5-high", Description: "CGA mode 5 palette, high intensity", Colors: CGA5High},
+	{Name: "ega64", Description: "All EGA colors, by register value", Colors: EGA64},
+	{Name: "c64", Description: "Commodore 64", Colors: C64},
+	{Name: "zx-spectrum", Description: "ZX Spectrum, normal then bright", Colors: ZXSpectrum},
+	{Name: "gameboy", Description: "Game Boy (DMG) greens", Colors: GameBoy},
+	{Name: "nes", Description: "NES, by PPU value", Colors: NES},
+	{Name: "pico8", Description: "PICO-8 fantasy console", Colors: PICO8},
+	{Name: "appleii", Description: "Apple II low-resolution colors", Colors: AppleII},
+}
+
+// Register makes pal loadable by name. Names are matched case-insensitively,
+// must be unique and cannot contain '#', which selects palettes in files.
+func Register(name string, pal color.Palette, description string) error {
+	if (name == "") || strings.ContainsRune(name, '#') {
+		return fmt.Errorf("invalid palette name: %q", name)
+	} else if _, ok := lookupRegistered(name); ok {
+		return fmt.Errorf("palette already registered: %q", name)
+	} else if len(pal) == 0 {
+		return fmt.Errorf("palette %q has no colors", name)
+	}
+
+	registry = append(registry, Entry{Name: name, Description: description, Colors: pal})
+	return nil
+}
+
+// Registered lists the registered palettes in registration order.
+func Registered() []Entry {
+	return slices.Clone(registry)
+}
+
+// RegisteredNames lists the names of the registered palettes in registration
+// order.
+func RegisteredNames() []string {
+	names := make([]string, len(registry))
+	for i, e := range registry {
+		names[i] = e.Name
+	}
+	return names
+}
+
+func lookupRegistered(name string) (Entry, bool) {
+	i := slices.IndexFunc(registry, func(e Entry) bool {
+		return strings.EqualFold(e.Name, name)
+	})
+	if i < 0 {
+		return Entry{}, false
+	}
+	return registry[i], true
+}
+
+// SearchPath lists the directories where LoadPalette looks for palette files
+// by name, after registered palettes. It defaults to the picproc/palettes
+// directory of the user configuration directory ($XDG_CONFIG_HOME on Linux).
+var SearchPath = defaultSearchPath()
+
+func defaultSearchPath() []string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(dir, "picproc", "palettes")}
+}
+
+// SearchPathEntries lists the palette files of any supported format in the
+// SearchPath directories, named by file name without extension. Files
+// shadowed by a registered palette or by a file in an earlier directory are
+// left out, and missing directories are skipped.
+func SearchPathEntries() ([]Entry, error) {
+	var res []Entry
+	seen := make(map[string]bool)
+	for _, dir := range SearchPath {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("could not read palette directory: %w", err)
+		}
+
+		for _, file := range files {
+			if file.IsDir() || (formatByExt(file.Name()) == nil) {
+				continue
+			}
+			name := strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+			if _, ok := lookupRegistered(name); ok || seen[strings.ToLower(name)] {
+				continue
+			}
+			seen[strings.ToLower(name)] = true
+
+			fileName := filepath.Join(dir, file.Name())
+			pals, err := LoadPalettesFromFile(fileName)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, Entry{Name: name, Colors: Concat(pals), File: fileName})
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,151 @@
+package palette
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// SwatchLayout lays out a palette as a grid of color chips on a white background,
+// optionally labelled with their index and hex value. Translucent colors are
+// drawn over a checkerboard.
+type SwatchLayout struct {
+	Columns  int
+	ChipSize int
+	Padding  int
+	Indexes  bool
+	Labels   bool
+	// Sort, if set, is the order chips are laid out in, as given to Order.
+	// Index labels still refer to the palette order.
+	Sort string
+}
+
+var DefaultSwatchLayout = SwatchLayout{Columns: 16, ChipSize: 48, Padding: 4, Indexes: true, Labels: true}
+
+var (
+	swatchBackground = color.White
+	swatchText       = color.Black
+	checkerLight     = color.Gray{Y: 0xCC}
+	checkerDark      = color.Gray{Y: 0x99}
+	chipOutline      = color.Gray{Y: 0x80}
+)
+
+func (s SwatchLayout) textLines() int {
+	n := 0
+	if s.Indexes {
+		n++
+	}
+	if s.Labels {
+		n++
+	}
+	return n
+}
+
+// cellSize returns the space taken by a chip and its labels, without padding.
+func (s SwatchLayout) cellSize(face font.Face) image.Point {
+	width := s.ChipSize
+	if s.Labels {
+		width = max(width, font.MeasureString(face, "#RRGGBBAA").Ceil())
+	}
+	height := s.ChipSize + s.textLines()*face.Metrics().Height.Ceil()
+	return image.Pt(width, height)
+}
+
+// Render draws the swatch of a palette.
+func (s SwatchLayout) Render(pal color.Palette) (*image.RGBA, error) {
+	if len(pal) == 0 {
+		return nil, fmt.Errorf("empty palette")
+	} else if s.Columns < 1 {
+		return nil, fmt.Errorf("invalid number of columns: %d", s.Columns)
+	} else if s.ChipSize < 1 {
+		return nil, fmt.Errorf("invalid chip size: %d", s.ChipSize)
+	} else if s.Padding < 0 {
+		return nil, fmt.Errorf("invalid padding: %d", s.Padding)
+	}
+
+	order := make([]int, len(pal))
+	for i := range order {
+		order[i] = i
+	}
+	if s.Sort != "" {
+		var err error
+		if order, err = Order(pal, s.Sort); err != nil {
+			return nil, err
+		}
+	}
+
+	face := basicfont.Face7x13
+	cell := s.cellSize(face)
+	cols := min(s.Columns, len(pal))
+	rows := (len(pal) + cols - 1) / cols
+	img := image.NewRGBA(image.Rect(0, 0,
+		cols*(cell.X+s.Padding)+s.Padding,
+		rows*(cell.Y+s.Padding)+s.Padding))
+	draw.Draw(img, img.Bounds(), image.NewUniform(swatchBackground), image.Point{}, draw.Src)
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(swatchText), Face: face}
+	lineHeight := face.Metrics().Height.Ceil()
+	ascent := face.Metrics().Ascent.Ceil()
+	for i, idx := range order {
+		col := pal[idx]
+		x := s.Padding + (i%cols)*(cell.X+s.Padding)
+		y := s.Padding + (i/cols)*(cell.Y+s.Padding)
+
+		chip := image.Rect(x, y, x+cell.X, y+s.ChipSize)
+		drawChecker(img, chip, max(s.ChipSize/4, 1))
+		draw.Draw(img, chip, image.NewUniform(col), image.Point{}, draw.Over)
+		drawOutline(img, chip, chipOutline)
+
+		var lines []string
+		if s.Indexes {
+			lines = append(lines, fmt.Sprintf("%d", idx))
+		}
+		if s.Labels {
+			lines = append(lines, Hex(col))
+		}
+		for j, line := range lines {
+			width := font.MeasureString(face, line).Ceil()
+			d.Dot = fixed.P(x+(cell.X-width)/2, y+s.ChipSize+j*lineHeight+ascent)
+			d.DrawString(line)
+		}
+	}
+
+	return img, nil
+}
+
+// drawOutline draws a one pixel frame along the inside edge of r, so light
+// chips stand out from the background.
+func drawOutline(img draw.Image, r image.Rectangle, c color.Color) {
+	src := image.NewUniform(c)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), src, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), src, image.Point{}, draw.Src)
+}
+
+func drawChecker(img draw.Image, r image.Rectangle, size int) {
+	for y := r.Min.Y; y < r.Max.Y; y += size {
+		for x := r.Min.X; x < r.Max.X; x += size {
+			c := checkerLight
+			if ((x-r.Min.X)/size+(y-r.Min.Y)/size)%2 == 1 {
+				c = checkerDark
+			}
+			sq := image.Rect(x, y, x+size, y+size).Intersect(r)
+			draw.Draw(img, sq, image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+}
+
+// Hex formats a color as #RRGGBB, or #RRGGBBAA if it is translucent.
+func Hex(col color.Color) string {
+	c := color.NRGBAModel.Convert(col).(color.NRGBA)
+	if c.A == 0xFF {
+		return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+	}
+	return fmt.Sprintf("#%02X%02X%02X%02X", c.R, c.G, c.B, c.A)
+}
@@ -0,0 +1,302 @@
+package palette
+
+import "image/color"
+
+// CGA text mode colors, in RGBI order. Color 6 is brown rather than dark yellow,
+// as on the IBM 5153 monitor.
+var (
+	CGA16 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0xAA, 0xFF},
+		color.RGBA{0x00, 0xAA, 0x00, 0xFF},
+		color.RGBA{0x00, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0xAA, 0x00, 0x00, 0xFF},
+		color.RGBA{0xAA, 0x00, 0xAA, 0xFF},
+		color.RGBA{0xAA, 0x55, 0x00, 0xFF},
+		color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0x55, 0x55, 0x55, 0xFF},
+		color.RGBA{0x55, 0x55, 0xFF, 0xFF},
+		color.RGBA{0x55, 0xFF, 0x55, 0xFF},
+		color.RGBA{0x55, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0x55, 0x55, 0xFF},
+		color.RGBA{0xFF, 0x55, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0x55, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	CGA16Lab, _        = LoadLabPalette("cga16")
+	CGA16LinearRGBA, _ = LoadLinearRGBAPalette("cga16")
+)
+
+// CGA 320x200 graphics mode palettes, with the background set to black. Palette
+// 0 is green, red and brown/yellow, palette 1 cyan, magenta and gray/white, and
+// the unofficial mode 5 palette cyan, red and gray/white. The high variants
+// have the intensity bit set.
+var (
+	CGA0 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0xAA, 0x00, 0xFF},
+		color.RGBA{0xAA, 0x00, 0x00, 0xFF},
+		color.RGBA{0xAA, 0x55, 0x00, 0xFF},
+	}
+	CGA0Lab, _        = LoadLabPalette("cga0")
+	CGA0LinearRGBA, _ = LoadLinearRGBAPalette("cga0")
+)
+
+var (
+	CGA0High = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x55, 0xFF, 0x55, 0xFF},
+		color.RGBA{0xFF, 0x55, 0x55, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0x55, 0xFF},
+	}
+	CGA0HighLab, _        = LoadLabPalette("cga0-high")
+	CGA0HighLinearRGBA, _ = LoadLinearRGBAPalette("cga0-high")
+)
+
+var (
+	CGA1 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0xAA, 0x00, 0xAA, 0xFF},
+		color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	}
+	CGA1Lab, _        = LoadLabPalette("cga1")
+	CGA1LinearRGBA, _ = LoadLinearRGBAPalette("cga1")
+)
+
+var (
+	CGA1High = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x55, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0x55, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	CGA1HighLab, _        = LoadLabPalette("cga1-high")
+	CGA1HighLinearRGBA, _ = LoadLinearRGBAPalette("cga1-high")
+)
+
+var (
+	CGA5 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0xAA, 0x00, 0x00, 0xFF},
+		color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+	}
+	CGA5Lab, _        = LoadLabPalette("cga5")
+	CGA5LinearRGBA, _ = LoadLinearRGBAPalette("cga5")
+)
+
+var (
+	CGA5High = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x55, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0x55, 0x55, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	CGA5HighLab, _        = LoadLabPalette("cga5-high")
+	CGA5HighLinearRGBA, _ = LoadLinearRGBAPalette("cga5-high")
+)
+
+// All 64 EGA colors, indexed by their rgbRGB register value.
+var (
+	EGA64              = ega64()
+	EGA64Lab, _        = LoadLabPalette("ega64")
+	EGA64LinearRGBA, _ = LoadLinearRGBAPalette("ega64")
+)
+
+// Commodore 64 colors as measured by Pepto on a PAL machine.
+var (
+	C64 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0x68, 0x37, 0x2B, 0xFF},
+		color.RGBA{0x70, 0xA4, 0xB2, 0xFF},
+		color.RGBA{0x6F, 0x3D, 0x86, 0xFF},
+		color.RGBA{0x58, 0x8D, 0x43, 0xFF},
+		color.RGBA{0x35, 0x28, 0x79, 0xFF},
+		color.RGBA{0xB8, 0xC7, 0x6F, 0xFF},
+		color.RGBA{0x6F, 0x4F, 0x25, 0xFF},
+		color.RGBA{0x43, 0x39, 0x00, 0xFF},
+		color.RGBA{0x9A, 0x67, 0x59, 0xFF},
+		color.RGBA{0x44, 0x44, 0x44, 0xFF},
+		color.RGBA{0x6C, 0x6C, 0x6C, 0xFF},
+		color.RGBA{0x9A, 0xD2, 0x84, 0xFF},
+		color.RGBA{0x6C, 0x5E, 0xB5, 0xFF},
+		color.RGBA{0x95, 0x95, 0x95, 0xFF},
+	}
+	C64Lab, _        = LoadLabPalette("c64")
+	C64LinearRGBA, _ = LoadLinearRGBAPalette("c64")
+)
+
+// ZX Spectrum colors, normal then bright, in attribute order. Bright black is
+// the same as black but kept so indexes match the attribute values.
+var (
+	ZXSpectrum = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0xD7, 0xFF},
+		color.RGBA{0xD7, 0x00, 0x00, 0xFF},
+		color.RGBA{0xD7, 0x00, 0xD7, 0xFF},
+		color.RGBA{0x00, 0xD7, 0x00, 0xFF},
+		color.RGBA{0x00, 0xD7, 0xD7, 0xFF},
+		color.RGBA{0xD7, 0xD7, 0x00, 0xFF},
+		color.RGBA{0xD7, 0xD7, 0xD7, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0x00, 0x00, 0xFF},
+		color.RGBA{0xFF, 0x00, 0xFF, 0xFF},
+		color.RGBA{0x00, 0xFF, 0x00, 0xFF},
+		color.RGBA{0x00, 0xFF, 0xFF, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0x00, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	ZXSpectrumLab, _        = LoadLabPalette("zx-spectrum")
+	ZXSpectrumLinearRGBA, _ = LoadLinearRGBAPalette("zx-spectrum")
+)
+
+// Game Boy (DMG) shades of green, darkest first.
+var (
+	GameBoy = color.Palette{
+		color.RGBA{0x0F, 0x38, 0x0F, 0xFF},
+		color.RGBA{0x30, 0x62, 0x30, 0xFF},
+		color.RGBA{0x8B, 0xAC, 0x0F, 0xFF},
+		color.RGBA{0x9B, 0xBC, 0x0F, 0xFF},
+	}
+	GameBoyLab, _        = LoadLabPalette("gameboy")
+	GameBoyLinearRGBA, _ = LoadLinearRGBAPalette("gameboy")
+)
+
+// NES (2C02 PPU) colors, indexed by their 6 bit PPU value. The NES generates
+// NTSC video directly, so these are one common approximation.
+var (
+	NES = color.Palette{
+		color.RGBA{0x7C, 0x7C, 0x7C, 0xFF},
+		color.RGBA{0x00, 0x00, 0xFC, 0xFF},
+		color.RGBA{0x00, 0x00, 0xBC, 0xFF},
+		color.RGBA{0x44, 0x28, 0xBC, 0xFF},
+		color.RGBA{0x94, 0x00, 0x84, 0xFF},
+		color.RGBA{0xA8, 0x00, 0x20, 0xFF},
+		color.RGBA{0xA8, 0x10, 0x00, 0xFF},
+		color.RGBA{0x88, 0x14, 0x00, 0xFF},
+		color.RGBA{0x50, 0x30, 0x00, 0xFF},
+		color.RGBA{0x00, 0x78, 0x00, 0xFF},
+		color.RGBA{0x00, 0x68, 0x00, 0xFF},
+		color.RGBA{0x00, 0x58, 0x00, 0xFF},
+		color.RGBA{0x00, 0x40, 0x58, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xBC, 0xBC, 0xBC, 0xFF},
+		color.RGBA{0x00, 0x78, 0xF8, 0xFF},
+		color.RGBA{0x00, 0x58, 0xF8, 0xFF},
+		color.RGBA{0x68, 0x44, 0xFC, 0xFF},
+		color.RGBA{0xD8, 0x00, 0xCC, 0xFF},
+		color.RGBA{0xE4, 0x00, 0x58, 0xFF},
+		color.RGBA{0xF8, 0x38, 0x00, 0xFF},
+		color.RGBA{0xE4, 0x5C, 0x10, 0xFF},
+		color.RGBA{0xAC, 0x7C, 0x00, 0xFF},
+		color.RGBA{0x00, 0xB8, 0x00, 0xFF},
+		color.RGBA{0x00, 0xA8, 0x00, 0xFF},
+		color.RGBA{0x00, 0xA8, 0x44, 0xFF},
+		color.RGBA{0x00, 0x88, 0x88, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xF8, 0xF8, 0xF8, 0xFF},
+		color.RGBA{0x3C, 0xBC, 0xFC, 0xFF},
+		color.RGBA{0x68, 0x88, 0xFC, 0xFF},
+		color.RGBA{0x98, 0x78, 0xF8, 0xFF},
+		color.RGBA{0xF8, 0x78, 0xF8, 0xFF},
+		color.RGBA{0xF8, 0x58, 0x98, 0xFF},
+		color.RGBA{0xF8, 0x78, 0x58, 0xFF},
+		color.RGBA{0xFC, 0xA0, 0x44, 0xFF},
+		color.RGBA{0xF8, 0xB8, 0x00, 0xFF},
+		color.RGBA{0xB8, 0xF8, 0x18, 0xFF},
+		color.RGBA{0x58, 0xD8, 0x54, 0xFF},
+		color.RGBA{0x58, 0xF8, 0x98, 0xFF},
+		color.RGBA{0x00, 0xE8, 0xD8, 0xFF},
+		color.RGBA{0x78, 0x78, 0x78, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xFC, 0xFC, 0xFC, 0xFF},
+		color.RGBA{0xA4, 0xE4, 0xFC, 0xFF},
+		color.RGBA{0xB8, 0xB8, 0xF8, 0xFF},
+		color.RGBA{0xD8, 0xB8, 0xF8, 0xFF},
+		color.RGBA{0xF8, 0xB8, 0xF8, 0xFF},
+		color.RGBA{0xF8, 0xA4, 0xC0, 0xFF},
+		color.RGBA{0xF0, 0xD0, 0xB0, 0xFF},
+		color.RGBA{0xFC, 0xE0, 0xA8, 0xFF},
+		color.RGBA{0xF8, 0xD8, 0x78, 0xFF},
+		color.RGBA{0xD8, 0xF8, 0x78, 0xFF},
+		color.RGBA{0xB8, 0xF8, 0xB8, 0xFF},
+		color.RGBA{0xB8, 0xF8, 0xD8, 0xFF},
+		color.RGBA{0x00, 0xFC, 0xFC, 0xFF},
+		color.RGBA{0xF8, 0xD8, 0xF8, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+	}
+	NESLab, _        = LoadLabPalette("nes")
+	NESLinearRGBA, _ = LoadLinearRGBAPalette("nes")
+)
+
+// PICO-8 fantasy console colors.
+var (
+	PICO8 = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0x1D, 0x2B, 0x53, 0xFF},
+		color.RGBA{0x7E, 0x25, 0x53, 0xFF},
+		color.RGBA{0x00, 0x87, 0x51, 0xFF},
+		color.RGBA{0xAB, 0x52, 0x36, 0xFF},
+		color.RGBA{0x5F, 0x57, 0x4F, 0xFF},
+		color.RGBA{0xC2, 0xC3, 0xC7, 0xFF},
+		color.RGBA{0xFF, 0xF1, 0xE8, 0xFF},
+		color.RGBA{0xFF, 0x00, 0x4D, 0xFF},
+		color.RGBA{0xFF, 0xA3, 0x00, 0xFF},
+		color.RGBA{0xFF, 0xEC, 0x27, 0xFF},
+		color.RGBA{0x00, 0xE4, 0x36, 0xFF},
+		color.RGBA{0x29, 0xAD, 0xFF, 0xFF},
+		color.RGBA{0x83, 0x76, 0x9C, 0xFF},
+		color.RGBA{0xFF, 0x77, 0xA8, 0xFF},
+		color.RGBA{0xFF, 0xCC, 0xAA, 0xFF},
+	}
+	PICO8Lab, _        = LoadLabPalette("pico8")
+	PICO8LinearRGBA, _ = LoadLinearRGBAPalette("pico8")
+)
+
+// Apple II low-resolution graphics colors, in color number order. Like the NES,
+// they come from NTSC artifacts, so these are an approximation.
+var (
+	AppleII = color.Palette{
+		color.RGBA{0x00, 0x00, 0x00, 0xFF},
+		color.RGBA{0xDD, 0x00, 0x33, 0xFF},
+		color.RGBA{0x00, 0x00, 0x99, 0xFF},
+		color.RGBA{0xDD, 0x22, 0xDD, 0xFF},
+		color.RGBA{0x00, 0x77, 0x22, 0xFF},
+		color.RGBA{0x55, 0x55, 0x55, 0xFF},
+		color.RGBA{0x22, 0x22, 0xFF, 0xFF},
+		color.RGBA{0x66, 0xAA, 0xFF, 0xFF},
+		color.RGBA{0x88, 0x55, 0x00, 0xFF},
+		color.RGBA{0xFF, 0x66, 0x00, 0xFF},
+		color.RGBA{0xAA, 0xAA, 0xAA, 0xFF},
+		color.RGBA{0xFF, 0x99, 0x88, 0xFF},
+		color.RGBA{0x11, 0xDD, 0x00, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0x00, 0xFF},
+		color.RGBA{0x44, 0xFF, 0x99, 0xFF},
+		color.RGBA{0xFF, 0xFF, 0xFF, 0xFF},
+	}
+	AppleIILab, _        = LoadLabPalette("appleii")
+	AppleIILinearRGBA, _ = LoadLinearRGBAPalette("appleii")
+)
+
+// ega64 builds the EGA palette: each channel has a primary bit worth 0xAA and
+// a secondary bit worth 0x55, with the primaries in bits 2-0 (red, green,
+// blue) and the secondaries in bits 5-3.
+func ega64() color.Palette {
+	level := func(i, primary, secondary int) uint8 {
+		return uint8(0xAA*(i>>primary&1) + 0x55*(i>>secondary&1))
+	}
+	res := make(color.Palette, 64)
+	for i := range res {
+		res[i] = color.RGBA{level(i, 2, 5), level(i, 1, 4), level(i, 0, 3), 0xFF}
+	}
+	return res
+}
@@ -1,6 +1,7 @@
 package palette
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
 	"image/color"
@@ -22,6 +23,18 @@ typedef struct tagPALETTEENTRY {
   BYTE peBlue;
   BYTE peFlags;
 } PALETTEENTRY;
+
+A file holding a single palette is laid out as
+  RIFF 'PAL ' (data, LIST 'INFO' (INAM))
+and one holding several palettes as
+  RIFF 'PAL ' (LIST 'PAL ' (data, LIST 'INFO' (INAM)), ...)
+where the INFO list carrying the palette name is optional.
+
+peFlags are Windows flags, and palettes are read as opaque. Palettes with
+translucent colors are followed by an empty 'alph' chunk at the same level,
+which other readers skip, and then store transparency in peFlags values above
+PC_RESERVED|PC_EXPLICIT|PC_NOCOLLAPSE, as 0xFF - alpha, so a fully
+transparent entry has peFlags 0xFF.
 */
 
 type PaletteConverter interface {
@@ -38,9 +51,13 @@ var (
 	riffType = riff.FourCC{'R', 'I', 'F', 'F'}
 	palType  = riff.FourCC{'P', 'A', 'L', ' '}
 	dataType = riff.FourCC{'d', 'a', 't', 'a'}
+	infoType = riff.FourCC{'I', 'N', 'F', 'O'}
+	nameType = riff.FourCC{'I', 'N', 'A', 'M'}
+	// alphaType marks peFlags as holding transparency
+	alphaType = riff.FourCC{'a', 'l', 'p', 'h'}
 )
 
-func ReadFrom(r io.Reader) ([]color.Palette, error) {
+func ReadFrom(r io.Reader) ([]Palette, error) {
 	formType, rd, err := riff.NewReader(r)
 	if err != nil {
 		return nil, fmt.Errorf("could not open RIFF stream: %w", err)
@@ -51,155 +68,221 @@ func ReadFrom(r io.Reader) ([]color.Palette, error) {
 	return readPalettes(rd, string(formType[:]))
 }
 
-func readPalettes(r *riff.Reader, ident string) ([]color.Palette, error) {
-	var res []color.Palette
+func readPalettes(r *riff.Reader, ident string) ([]Palette, error) {
+	var res []Palette
+	// palettes read from data chunks at this level, named by an INFO list
+	// at the same level, with their peFlags
+	var own []int
+	var flags [][]byte
+	var name string
+	alpha := false
 
-	for {
+	for i := 0; ; i++ {
 		id, size, data, err := r.Next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
 
-			return res, fmt.Errorf("could not read chunk %q#%d: %w", ident, len(res), err)
+			return res, fmt.Errorf("could not read chunk %q#%d: %w", ident, i, err)
 		}
 
-		if id == riff.LIST {
+		switch id {
+		case riff.LIST:
 			listType, list, lerr := riff.NewListReader(size, data)
 			if lerr != nil {
-				return res, fmt.Errorf("could not read list from chunk %q#%d: %w", ident, len(res), lerr)
-			} else if listType != palType {
-				return nil, fmt.Errorf("chunk %q#%d unsupported type: %s", ident, len(res), string(listType[:]))
+				return res, fmt.Errorf("could not read list from chunk %q#%d: %w", ident, i, lerr)
 			}
 
-			if listRes, lerr := readPalettes(list, fmt.Sprintf("%s%d.%s", ident, len(res), listType[:])); lerr != nil {
-				return append(res, listRes...), lerr
+			switch listType {
+			case palType:
+				listRes, lerr := readPalettes(list, fmt.Sprintf("%s%d.%s", ident, i, listType[:]))
+				res = append(res, listRes...)
+				if lerr != nil {
+					return res, lerr
+				}
+			case infoType:
+				if name, lerr = readName(list); lerr != nil {
+					return res, fmt.Errorf("could not read info from chunk %q#%d: %w", ident, i, lerr)
+				}
+			}
+		case dataType:
+			pal, palFlags, err := readPalette(data, fmt.Sprintf("%s%d", ident, i))
+			if err != nil {
+				return res, err
 			}
-		} else if id != dataType {
-			return res, fmt.Errorf("unsupported chunk type in %q#%d: %s", ident, len(res), id)
-		}
 
-		pal, err := readPalette(data, fmt.Sprintf("%s%d", ident, len(res)))
-		if err != nil {
-			return res, err
+			own = append(own, len(res))
+			flags = append(flags, palFlags)
+			res = append(res, Palette{Colors: pal})
+		case alphaType:
+			alpha = true
 		}
+	}
 
-		res = append(res, pal)
+	for j, i := range own {
+		res[i].Name = name
+		if alpha {
+			for k, col := range res[i].Colors {
+				c := col.(color.NRGBA)
+				c.A = flagsToAlpha(flags[j][k])
+				res[i].Colors[k] = c
+			}
+		}
 	}
 
 	return res, nil
 }
 
-func readPalette(r io.Reader, ident string) (color.Palette, error) {
+func readName(r *riff.Reader) (string, error) {
+	var name string
+	for {
+		id, _, data, err := r.Next()
+		if err != nil {
+			if err == io.EOF {
+				return name, nil
+			}
+			return name, err
+		}
+
+		if id == nameType {
+			b, err := io.ReadAll(data)
+			if err != nil {
+				return name, err
+			}
+			name = string(bytes.TrimRight(b, "\x00"))
+		}
+	}
+}
+
+// readPalette reads opaque colors, along with the peFlags of each.
+func readPalette(r io.Reader, ident string) (color.Palette, []byte, error) {
 	buf := make([]byte, 2)
 
 	n, err := r.Read(buf)
 	if err != nil {
-		return nil, fmt.Errorf("could not read version from chunk %s: %w", ident, err)
+		return nil, nil, fmt.Errorf("could not read version from chunk %s: %w", ident, err)
 	} else if n != 2 {
-		return nil, fmt.Errorf("not enough bytes in %s to read version number: %d", ident, n)
+		return nil, nil, fmt.Errorf("not enough bytes in %s to read version number: %d", ident, n)
 	}
 
 	ver := binary.BigEndian.Uint16(buf)
 	if ver != 3 {
-		return nil, fmt.Errorf("unsupported palette version in chunk %s: %d", ident, ver)
+		return nil, nil, fmt.Errorf("unsupported palette version in chunk %s: %d", ident, ver)
 	}
 
 	n, err = r.Read(buf)
 	if err != nil {
-		return nil, fmt.Errorf("could not read number of entries from chunk %s: %w", ident, err)
+		return nil, nil, fmt.Errorf("could not read number of entries from chunk %s: %w", ident, err)
 	} else if n != 2 {
-		return nil, fmt.Errorf("not enough bytes in %s to read number of entries: %d", ident, n)
+		return nil, nil, fmt.Errorf("not enough bytes in %s to read number of entries: %d", ident, n)
 	}
 
 	count := binary.LittleEndian.Uint16(buf)
 	res := make([]color.Color, count)
+	flags := make([]byte, count)
 	buf4 := make([]byte, 4)
 	for i := range count {
 		n, err = r.Read(buf4)
 		if err != nil {
-			return res, fmt.Errorf("could not read color %d/%d from chunk %s: %w", i, count, ident, err)
+			return res, flags, fmt.Errorf("could not read color %d/%d from chunk %s: %w", i, count, ident, err)
 		} else if n != 4 {
-			return res, fmt.Errorf("not enough bytes to read color %d/%d from chunk %s: %d", i, count, ident, n)
+			return res, flags, fmt.Errorf("not enough bytes to read color %d/%d from chunk %s: %d", i, count, ident, n)
 		}
 
-		res[i] = color.RGBA{
-			R: buf4[0],
-			G: buf4[1],
-			B: buf4[2],
-			A: 0xFF,
-		}
+		res[i] = color.NRGBA{R: buf4[0], G: buf4[1], B: buf4[2], A: 0xFF}
+		flags[i] = buf4[3]
 	}
 
-	return res, nil
+	return res, flags, nil
 }
 
-func WriteTo(w io.Writer, pals []color.Palette) (int64, error) {
-	n := 4
-	for _, pal := range pals {
-		n += 4 + 4 + 4 + len(pal)*4 // chunk id + chunk size + palVersion + palNumEntries + 4 bytes/color
-	}
+// WriteTo saves the palettes in a RIFF stream and returns the number of bytes
+// written.
+func WriteTo(w io.Writer, pals []Palette) (int64, error) {
+	var body bytes.Buffer
+	body.Write(palType[:])
 
-	if err := writeByes(w, riffType[:]); err != nil {
-		return 0, fmt.Errorf("could not write RIFF magic: %w", err)
+	if len(pals) == 1 {
+		if err := appendPalette(&body, pals[0]); err != nil {
+			return 0, fmt.Errorf("could not write chunk 0: %w", err)
+		}
+	} else {
+		for i, pal := range pals {
+			var list bytes.Buffer
+			list.Write(palType[:])
+			if err := appendPalette(&list, pal); err != nil {
+				return 0, fmt.Errorf("could not write chunk %d: %w", i, err)
+			}
+			appendChunk(&body, riff.LIST, list.Bytes())
+		}
 	}
 
-	if err := writeByes(w, binary.LittleEndian.AppendUint32(nil, uint32(n))); err != nil {
-		return 0, fmt.Errorf("could not write document size: %w", err)
-	}
+	var doc bytes.Buffer
+	appendChunk(&doc, riffType, body.Bytes())
 
-	if err := writeByes(w, palType[:]); err != nil {
-		return 0, fmt.Errorf("could not write content type: %w", err)
-	}
-
-	var count int64
-	for i, pal := range pals {
-		if n, err := writePalette(w, pal); err != nil {
-			count += n
-			return count, fmt.Errorf("could not write chunk %d: %w", i, err)
-		} else {
-			count += n
-		}
+	n, err := doc.WriteTo(w)
+	if err != nil {
+		return n, fmt.Errorf("could not write RIFF stream: %w", err)
 	}
-
-	return count, nil
+	return n, nil
 }
 
-func writePalette(w io.Writer, pal color.Palette) (int64, error) {
-	if err := writeByes(w, dataType[:]); err != nil {
-		return 0, fmt.Errorf("could not write type: %w", err)
+func appendPalette(buf *bytes.Buffer, pal Palette) error {
+	if len(pal.Colors) > 0xFFFF {
+		return fmt.Errorf("too many colors: %d", len(pal.Colors))
 	}
 
-	n := 4 + len(pal)*4
-	if err := writeByes(w, binary.LittleEndian.AppendUint32(nil, uint32(n))); err != nil {
-		return 0, fmt.Errorf("could not write chunk size: %w", err)
+	alpha := false
+	data := make([]byte, 0, 4+len(pal.Colors)*4)
+	data = append(data, 0, 0x03)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(pal.Colors)))
+	for _, col := range pal.Colors {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		flags := alphaToFlags(c.A)
+		alpha = alpha || (flags != 0)
+		data = append(data, c.R, c.G, c.B, flags)
 	}
-
-	if err := writeByes(w, []byte{0, 0x03}); err != nil {
-		return 0, fmt.Errorf("could not write palette version: %w", err)
+	appendChunk(buf, dataType, data)
+	if alpha {
+		appendChunk(buf, alphaType, nil)
 	}
 
-	if err := writeByes(w, binary.LittleEndian.AppendUint16(nil, uint16(len(pal)))); err != nil {
-		return 0, fmt.Errorf("could not write number of colors: %w", err)
+	if pal.Name != "" {
+		var info bytes.Buffer
+		info.Write(infoType[:])
+		appendChunk(&info, nameType, append([]byte(pal.Name), 0))
+		appendChunk(buf, riff.LIST, info.Bytes())
 	}
 
-	for i, col := range pal {
-		c := color.RGBA64Model.Convert(col).(color.RGBA)
-		if err := writeByes(w, []byte{c.R, c.G, c.B, 0x00}); err != nil {
-			return int64(i), fmt.Errorf("could not write color %d/%d: %w", i, len(pal), err)
-		}
-	}
+	return nil
+}
+
+// maxWindowsFlags is PC_RESERVED|PC_EXPLICIT|PC_NOCOLLAPSE
+const maxWindowsFlags = 0x07
 
-	return int64(len(pal)), nil
+func flagsToAlpha(flags byte) uint8 {
+	if flags <= maxWindowsFlags {
+		return 0xFF
+	}
+	return 0xFF - flags
 }
 
-func writeByes(w io.Writer, b []byte) error {
-	n, err := w.Write(b)
-	if err != nil {
-		return err
-	} else if n != len(b) {
-		return fmt.Errorf("wrote only %d/%d bytes", n, len(b))
+// alphaToFlags stores nearly opaque colors as fully opaque, as their
+// transparency would collide with Windows flags.
+func alphaToFlags(alpha uint8) byte {
+	if 0xFF-alpha <= maxWindowsFlags {
+		return 0
 	}
+	return 0xFF - alpha
+}
 
-	return nil
+// appendChunk writes a chunk header followed by data, padded to an even size.
+func appendChunk(buf *bytes.Buffer, id riff.FourCC, data []byte) {
+	buf.Write(id[:])
+	buf.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte(0)
+	}
 }
@@ -0,0 +1,54 @@
+package palette
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+func TestRIFFAlpha(t *testing.T) {
+	pal := color.Palette{
+		color.NRGBA{R: 1, G: 2, B: 3, A: 0xFF},
+		color.NRGBA{R: 4, G: 5, B: 6, A: 0x80},
+		color.NRGBA{},
+	}
+
+	var buf bytes.Buffer
+	if _, err := WriteTo(&buf, []Palette{{Name: "alpha", Colors: pal}}); err != nil {
+		t.Fatal(err)
+	}
+	pals, err := ReadFrom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, col := range pals[0].Colors {
+		if col != pal[i] {
+			t.Errorf("color %d: got %v, want %v", i, col, pal[i])
+		}
+	}
+}
+
+func TestRIFFForeignFlags(t *testing.T) {
+	// a palette written by another tool, with flags bits set but no alpha
+	// marker
+	data := []byte{0, 0x03}
+	data = binary.LittleEndian.AppendUint16(data, 2)
+	data = append(data, 10, 20, 30, 0x80, 40, 50, 60, 0xFF)
+
+	var body bytes.Buffer
+	body.Write(palType[:])
+	appendChunk(&body, dataType, data)
+	var doc bytes.Buffer
+	appendChunk(&doc, riffType, body.Bytes())
+
+	pals, err := ReadFrom(&doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, col := range pals[0].Colors {
+		if _, _, _, a := col.RGBA(); a != 0xFFFF {
+			t.Errorf("color %d: got alpha %d, want opaque", i, a)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package palette
+
+import (
+	"cmp"
+	"fmt"
+	"image/color"
+	"maps"
+	"math"
+	"slices"
+
+	"picproc/okcolor"
+)
+
+// AchromaticChroma is the Oklab chroma under which hue is meaningless.
+const AchromaticChroma = 0.0005
+
+// hueBins is the number of hue sectors colors are grouped in when sorting by
+// hue, so that similar hues are ordered by lightness.
+const hueBins = 24
+
+// orderFunc returns the indexes of the palette colors in some order.
+type orderFunc func(lch []okcolor.LCh, p color.Palette) []int
+
+var sortOrders = map[string]orderFunc{
+	"rgb":       orderRGB,
+	"hue":       orderHue,
+	"lightness": orderLightness,
+	"chroma":    orderChroma,
+	"luminance": orderLuminance,
+	"nearest":   orderNearest,
+}
+
+func SortOrderNames() []string {
+	return slices.Sorted(maps.Keys(sortOrders))
+}
+
+// Order returns the indexes of the palette colors in the named sort order:
+//   - rgb: by red, green, blue and alpha values
+//   - hue: grays first by lightness, then by OkLCh hue and lightness
+//   - lightness: by Oklab lightness
+//   - chroma: by OkLCh chroma, then lightness
+//   - luminance: by relative luminance (CIE Y)
+//   - nearest: a path from the darkest color through each nearest one in
+//     Oklab, giving smooth gradients
+func Order(p color.Palette, by string) ([]int, error) {
+	f, ok := sortOrders[by]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort order: %q", by)
+	}
+
+	lch := make([]okcolor.LCh, len(p))
+	for i, col := range p {
+		lch[i] = okcolor.LabModel.Convert(col).(okcolor.Lab).LCh()
+	}
+	return f(lch, p), nil
+}
+
+// SortPaletteBy sorts the palette in place in the named order, as given by
+// Order.
+func SortPaletteBy(p color.Palette, by string) error {
+	order, err := Order(p, by)
+	if err != nil {
+		return err
+	}
+
+	sorted := make(color.Palette, len(p))
+	for i, j := range order {
+		sorted[i] = p[j]
+	}
+	copy(p, sorted)
+	return nil
+}
+
+// sortedIndexes returns the indexes of n items stably sorted by cmpFunc.
+func sortedIndexes(n int, cmpFunc func(i, j int) int) []int {
+	order := make([]int, n)
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, cmpFunc)
+	return order
+}
+
+// compareRGBA orders colors by their red, green, blue and alpha channels, in
+// that order.
+func compareRGBA(c1, c2 color.Color) int {
+	r1, g1, b1, a1 := c1.RGBA()
+	r2, g2, b2, a2 := c2.RGBA()
+	return cmp.Or(cmp.Compare(r1, r2), cmp.Compare(g1, g2), cmp.Compare(b1, b2), cmp.Compare(a1, a2))
+}
+
+func orderRGB(_ []okcolor.LCh, p color.Palette) []int {
+	return sortedIndexes(len(p), func(i, j int) int {
+		return compareRGBA(p[i], p[j])
+	})
+}
+
+func orderHue(lch []okcolor.LCh, _ color.Palette) []int {
+	bin := func(lc okcolor.LCh) int {
+		if lc.C < AchromaticChroma {
+			return -1
+		}
+		h := math.Mod(lc.H+2*math.Pi, 2*math.Pi)
+		return min(int(h/(2*math.Pi)*hueBins), hueBins-1)
+	}
+	return sortedIndexes(len(lch), func(i, j int) int {
+		return cmp.Or(cmp.Compare(bin(lch[i]), bin(lch[j])), cmp.Compare(lch[i].L, lch[j].L))
+	})
+}
+
+func orderLightness(lch []okcolor.LCh, _ color.Palette) []int {
+	return sortedIndexes(len(lch), func(i, j int) int {
+		return cmp.Compare(lch[i].L, lch[j].L)
+	})
+}
+
+func orderChroma(lch []okcolor.LCh, _ color.Palette) []int {
+	return sortedIndexes(len(lch), func(i, j int) int {
+		return cmp.Or(cmp.Compare(lch[i].C, lch[j].C), cmp.Compare(lch[i].L, lch[j].L))
+	})
+}
+
+func orderLuminance(_ []okcolor.LCh, p color.Palette) []int {
+	y := make([]float64, len(p))
+	for i, col := range p {
+		lc := okcolor.LinearRGBAModel.Convert(col).(okcolor.LinearRGBA)
+		y[i] = 0.2126*lc.R + 0.7152*lc.G + 0.0722*lc.B
+	}
+	return sortedIndexes(len(p), func(i, j int) int {
+		return cmp.Compare(y[i], y[j])
+	})
+}
+
+// maxTwoOptPasses bounds the refinement of the nearest neighbour path.
+const maxTwoOptPasses = 16
+
+// orderNearest builds an open path through all colors, starting from the
+// darkest one and always moving to the nearest unvisited color, then shortens
+// it by reversing segments whenever that removes a crossing (2-opt).
+func orderNearest(lch []okcolor.LCh, _ color.Palette) []int {
+	n := len(lch)
+	if n == 0 {
+		return nil
+	}
+
+	lab := make([]okcolor.Lab, n)
+	for i, lc := range lch {
+		lab[i] = lc.Lab()
+	}
+	dist := func(i, j int) float64 {
+		return okcolor.DeltaEOK(lab[i], lab[j])
+	}
+
+	start := 0
+	for i := range lch {
+		if lch[i].L < lch[start].L {
+			start = i
+		}
+	}
+
+	path := make([]int, 0, n)
+	visited := make([]bool, n)
+	for cur := start; len(path) < n; {
+		path = append(path, cur)
+		visited[cur] = true
+
+		next, best := -1, math.MaxFloat64
+		for j := range n {
+			if d := dist(cur, j); !visited[j] && (d < best) {
+				next, best = j, d
+			}
+		}
+		cur = next
+	}
+
+	// the path is open, so reversing a suffix only changes one edge, and
+	// the first color stays in place
+	for range maxTwoOptPasses {
+		improved := false
+		for i := 0; i < n-2; i++ {
+			for j := i + 2; j < n; j++ {
+				before := dist(path[i], path[i+1])
+				after := dist(path[i], path[j])
+				if j+1 < n {
+					before += dist(path[j], path[j+1])
+					after += dist(path[i+1], path[j+1])
+				}
+				if after < before-1e-12 {
+					slices.Reverse(path[i+1 : j+1])
+					improved = true
+				}
+			}
+		}
+		if !improved {
+			break
+		}
+	}
+
+	return path
+}
@@ -0,0 +1,201 @@
+package palette
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"io"
+	"strconv"
+	"strings"
+)
+
+/*
+JASC-PAL
+0100
+2
+0 0 0
+255 255 255
+*/
+
+const (
+	jascMagic   = "JASC-PAL"
+	jascVersion = "0100"
+	// jascMaxColors bounds the number of colors given by the header, which
+	// cannot be trusted
+	jascMaxColors = 65535
+	paintNETMagic = ";paint.net Palette File"
+)
+
+func detectJASC(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(jascMagic))
+}
+
+func ReadJASC(r io.Reader) (color.Palette, error) {
+	scanner := bufio.NewScanner(r)
+	var header [3]string
+	for i := range header {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return nil, fmt.Errorf("could not read JASC header: %w", err)
+			}
+			return nil, fmt.Errorf("truncated JASC header")
+		}
+		header[i] = strings.TrimSpace(scanner.Text())
+	}
+
+	if header[0] != jascMagic {
+		return nil, fmt.Errorf("invalid JASC header: %q", header[0])
+	} else if header[1] != jascVersion {
+		return nil, fmt.Errorf("unsupported JASC version: %q", header[1])
+	}
+
+	count, err := strconv.Atoi(header[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid JASC number of colors: %w", err)
+	} else if (count < 0) || (count > jascMaxColors) {
+		return nil, fmt.Errorf("invalid JASC number of colors: %d", count)
+	}
+
+	// grown as colors are read, as the header may not match the body
+	var res color.Palette
+	for i := range count {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return res, fmt.Errorf("could not read JASC color %d/%d: %w", i, count, err)
+			}
+			return res, fmt.Errorf("not enough JASC colors: %d/%d", i, count)
+		}
+
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			return res, fmt.Errorf("invalid JASC color %d/%d: %q", i, count, scanner.Text())
+		}
+
+		var rgb [3]uint8
+		for j := range rgb {
+			v, err := strconv.ParseUint(fields[j], 10, 8)
+			if err != nil {
+				return res, fmt.Errorf("invalid JASC color component %d/%d: %w", i, count, err)
+			}
+			rgb[j] = uint8(v)
+		}
+		res = append(res, color.RGBA{R: rgb[0], G: rgb[1], B: rgb[2], A: 0xFF})
+	}
+
+	return res, nil
+}
+
+func WriteJASC(w io.Writer, pal color.Palette) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\r\n%s\r\n%d\r\n", jascMagic, jascVersion, len(pal))
+	for _, col := range pal {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		fmt.Fprintf(&buf, "%d %d %d\r\n", c.R, c.G, c.B)
+	}
+
+	return buf.WriteTo(w)
+}
+
+func detectPaintNET(header []byte) bool {
+	return bytes.HasPrefix(bytes.ToLower(header), []byte(strings.ToLower(paintNETMagic)))
+}
+
+// ReadPaintNET reads a Paint.NET palette, made of AARRGGBB hex lines and
+// comments starting with a semicolon.
+func ReadPaintNET(r io.Reader) (color.Palette, error) {
+	return readHexLines(r, ";", true)
+}
+
+func WritePaintNET(w io.Writer, pal color.Palette, name string) (int64, error) {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, paintNETMagic)
+	if name != "" {
+		fmt.Fprintf(&buf, ";Palette Name: %s\n", name)
+	}
+	fmt.Fprintf(&buf, ";Colors: %d\n", len(pal))
+	for _, col := range pal {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		fmt.Fprintf(&buf, "%02X%02X%02X%02X\n", c.A, c.R, c.G, c.B)
+	}
+
+	return buf.WriteTo(w)
+}
+
+// ReadHex reads a list of hex colors, one per line, in any form accepted by
+// ParseHex.
+func ReadHex(r io.Reader) (color.Palette, error) {
+	return readHexLines(r, "//", false)
+}
+
+func WriteHex(w io.Writer, pal color.Palette) (int64, error) {
+	var buf bytes.Buffer
+	for _, col := range pal {
+		c := color.NRGBAModel.Convert(col).(color.NRGBA)
+		if c.A == 0xFF {
+			fmt.Fprintf(&buf, "%02x%02x%02x\n", c.R, c.G, c.B)
+		} else {
+			fmt.Fprintf(&buf, "%02x%02x%02x%02x\n", c.R, c.G, c.B, c.A)
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// readHexLines parses one hex color per line with ParseHex, skipping blank
+// lines and comments. Colors with alpha are AARRGGBB if alphaFirst is set,
+// RRGGBBAA otherwise.
+func readHexLines(r io.Reader, comment string, alphaFirst bool) (color.Palette, error) {
+	var res color.Palette
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\uFEFF"))
+		if (line == "") || strings.HasPrefix(line, comment) {
+			continue
+		}
+
+		c, err := ParseHex(line)
+		if err != nil {
+			return res, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+
+		if digits := strings.TrimPrefix(line, "#"); alphaFirst && ((len(digits) == 4) || (len(digits) == 8)) {
+			c = color.NRGBA{R: c.G, G: c.B, B: c.A, A: c.R}
+		}
+		res = append(res, c)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return res, fmt.Errorf("could not read hex colors: %w", err)
+	}
+	return res, nil
+}
+
+// ParseHex reads a color written as RGB, RGBA, RRGGBB or RRGGBBAA hex digits,
+// optionally prefixed by #.
+func ParseHex(s string) (color.NRGBA, error) {
+	digits := strings.TrimPrefix(s, "#")
+	if (len(digits) == 3) || (len(digits) == 4) {
+		var sb strings.Builder
+		for _, d := range digits {
+			sb.WriteRune(d)
+			sb.WriteRune(d)
+		}
+		digits = sb.String()
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("invalid hex color %q: %w", s, err)
+	}
+
+	switch len(b) {
+	case 3:
+		return color.NRGBA{R: b[0], G: b[1], B: b[2], A: 0xFF}, nil
+	case 4:
+		return color.NRGBA{R: b[0], G: b[1], B: b[2], A: b[3]}, nil
+	default:
+		return color.NRGBA{}, fmt.Errorf("invalid hex color: %q", s)
+	}
+}
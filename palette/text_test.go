@@ -0,0 +1,102 @@
+package palette
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestReadJASC(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		colors  int
+		wantErr bool
+	}{
+		{name: "valid", in: "JASC-PAL\r\n0100\r\n2\r\n0 0 0\r\n255 255 255\r\n", colors: 2},
+		{name: "empty", in: "JASC-PAL\n0100\n0\n", colors: 0},
+		{name: "negative count", in: "JASC-PAL\n0100\n-1\n", wantErr: true},
+		{name: "huge count", in: "JASC-PAL\n0100\n4000000000\n0 0 0\n", wantErr: true},
+		{name: "count over limit", in: "JASC-PAL\n0100\n65536\n0 0 0\n", wantErr: true},
+		{name: "short body", in: "JASC-PAL\n0100\n3\n0 0 0\n255 255 255\n", wantErr: true},
+		{name: "truncated header", in: "JASC-PAL\n0100\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pal, err := ReadJASC(strings.NewReader(tt.in))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %d colors, want an error", len(pal))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if len(pal) != tt.colors {
+				t.Fatalf("got %d colors, want %d", len(pal), tt.colors)
+			}
+		})
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    color.NRGBA
+		wantErr bool
+	}{
+		{in: "#123", want: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xFF}},
+		{in: "1234", want: color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x44}},
+		{in: "#a0b1c2", want: color.NRGBA{R: 0xA0, G: 0xB1, B: 0xC2, A: 0xFF}},
+		{in: "A0B1C2D3", want: color.NRGBA{R: 0xA0, G: 0xB1, B: 0xC2, A: 0xD3}},
+		{in: "", wantErr: true},
+		{in: "#12", wantErr: true},
+		{in: "#12345", wantErr: true},
+		{in: "#ggg", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			c, err := ParseHex(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want an error", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			} else if c != tt.want {
+				t.Fatalf("got %v, want %v", c, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadHexAlphaOrder(t *testing.T) {
+	want := color.Palette{
+		color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xFF},
+		color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0x80},
+	}
+
+	hexPal, err := ReadHex(strings.NewReader("// comment\n#112233\n11223380\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	netPal, err := ReadPaintNET(strings.NewReader(";paint.net Palette File\nFF112233\n80112233\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, pal := range map[string]color.Palette{"hex": hexPal, "paint.net": netPal} {
+		if len(pal) != len(want) {
+			t.Fatalf("%s: got %d colors, want %d", name, len(pal), len(want))
+		}
+		for i := range want {
+			if pal[i] != want[i] {
+				t.Errorf("%s: color %d is %v, want %v", name, i, pal[i], want[i])
+			}
+		}
+	}
+}